@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/cfg"
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"github.com/zhaojunlucky/golib/pkg/security"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `usage: cfgctl <command> [flags]
+
+commands:
+  print   -app <app> [-file config.yaml] [load flags]          print the merged config
+  get     -app <app> [-file config.yaml] [load flags] <key>    print the value of a dotted key
+  set     -app <app> [-file config.yaml] [-target file] <key> <value>
+                                                              set a dotted key in the user config
+  explain -app <app> [-file config.yaml] [load flags] <key>    show the key in every config source
+  encrypt -pub <public key> [-raw] [value]                     encrypt value (or stdin without its trailing newline,
+                                                              unless -raw) as a !secret config value
+  sign    -key <private key> <file>...                         write the detached .sig signature of config files
+
+-file takes comma separated names to find any of them in each config dir, two
+of them in the same dir are a conflict.
+
+load flags of print, get and explain:
+  -key <private key>                                          PEM encoded EC private key to decrypt !secret values
+  -key-env <variable>                                         variable holding the PEM encoded EC private key
+  -walk-parents [-stop .git]                                  also search the parents of the current dir up to -stop
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "print":
+		err = printCfg(os.Args[2:])
+	case "get":
+		err = get(os.Args[2:])
+	case "set":
+		err = set(os.Args[2:])
+	case "explain":
+		err = explain(os.Args[2:])
+	case "encrypt":
+		err = encrypt(os.Args[2:], os.Stdin, os.Stdout)
+	case "sign":
+		err = sign(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cfgctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+type cfgFlags struct {
+	flags   *flag.FlagSet
+	envs    env.Env
+	appName *string
+	cfgFile *string
+	keyPath *string
+	keyEnv  *string
+	walk    *bool
+	stop    *string
+}
+
+func newCfgFlags(name string) *cfgFlags {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return &cfgFlags{
+		flags:   flags,
+		envs:    env.OSEnv,
+		appName: flags.String("app", "", "app name"),
+		cfgFile: flags.String("file", "config.yaml", "config file name, or comma separated names of which each config dir holds one"),
+	}
+}
+
+// newLoadFlags adds the flags of the key which decrypts !secret values.
+func newLoadFlags(name string) *cfgFlags {
+	f := newCfgFlags(name)
+	f.keyPath = f.flags.String("key", "", "PEM encoded EC private key to decrypt !secret values")
+	f.keyEnv = f.flags.String("key-env", "", "variable holding the PEM encoded EC private key")
+	f.walk = f.flags.Bool("walk-parents", false, "also search the parents of the current dir for config files")
+	f.stop = f.flags.String("stop", ".git", "comma separated files or dirs which stop -walk-parents")
+	return f
+}
+
+// loader returns the loader of the config files with the names, the secret key
+// and the path resolver of the flags.
+func (f *cfgFlags) loader() (*cfg.Loader, error) {
+	loader := cfg.NewLoader()
+	if names := strings.Split(*f.cfgFile, ","); len(names) > 1 {
+		loader.Names = names
+	}
+	if *f.walk {
+		loader.Resolver = cfg.NewPathResolver(f.envs)
+		loader.Resolver.WalkParents = true
+		if *f.stop != "" {
+			loader.Resolver.StopMarkers = strings.Split(*f.stop, ",")
+		}
+	}
+	var err error
+	switch {
+	case *f.keyPath != "" && *f.keyEnv != "":
+		return nil, fmt.Errorf("-key and -key-env are exclusive")
+	case *f.keyPath != "":
+		loader.Secrets, err = cfg.NewECIESSecretsFromFile(*f.keyPath)
+	case *f.keyEnv != "":
+		loader.Secrets, err = cfg.NewECIESSecretsFromEnv(f.envs, *f.keyEnv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// parse parses the flags and returns the nArgs positional arguments.
+func (f *cfgFlags) parse(args []string, nArgs int) ([]string, error) {
+	if err := f.flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *f.appName == "" {
+		return nil, fmt.Errorf("-app is required")
+	}
+	if f.flags.NArg() != nArgs {
+		return nil, fmt.Errorf("expected %d arguments, got %d", nArgs, f.flags.NArg())
+	}
+	return f.flags.Args(), nil
+}
+
+func printYAML(value any) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func printCfg(args []string) error {
+	f := newLoadFlags("print")
+	if _, err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	loader, err := f.loader()
+	if err != nil {
+		return err
+	}
+	layered, err := loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil)
+	if err != nil {
+		return err
+	}
+	return printYAML(layered.Data())
+}
+
+func get(args []string) error {
+	f := newLoadFlags("get")
+	positional, err := f.parse(args, 1)
+	if err != nil {
+		return err
+	}
+
+	loader, err := f.loader()
+	if err != nil {
+		return err
+	}
+	layered, err := loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil)
+	if err != nil {
+		return err
+	}
+	value, ok := layered.Get(positional[0])
+	if !ok {
+		return fmt.Errorf("key %s not found", positional[0])
+	}
+	return printYAML(value)
+}
+
+func set(args []string) error {
+	f := newCfgFlags("set")
+	target := f.flags.String("target", "", "config file to modify, default is the user config")
+	positional, err := f.parse(args, 2)
+	if err != nil {
+		return err
+	}
+
+	path := *target
+	if path == "" {
+		if path, err = userCfgPath(*f.appName, *f.cfgFile, f.envs); err != nil {
+			return err
+		}
+	}
+
+	editor, err := cfg.OpenEditor(path)
+	if err != nil {
+		return err
+	}
+	if err = editor.Set(positional[0], parseValue(positional[1])); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err = editor.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("set %s in %s\n", positional[0], path)
+	return nil
+}
+
+// parseValue parses the value of set as a YAML node, so numbers, bools, lists,
+// maps and tags like !secret are written as given. Empty values, null and
+// invalid YAML are set as the string.
+func parseValue(s string) any {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(s), node); err != nil || len(node.Content) == 0 || node.Content[0].Tag == "!!null" {
+		return s
+	}
+	return node.Content[0]
+}
+
+// userCfgPath returns the path of the <APP>_CONFIG override, or the config file
+// in the user's config dir. If cfgFile holds comma separated names, it is the
+// existing one or else the first.
+func userCfgPath(appName string, cfgFile string, envs env.Env) (string, error) {
+	resolver := cfg.NewPathResolver(envs)
+	if override := resolver.Env.Get(cfg.OverrideEnvName(appName)); override != "" {
+		return override, nil
+	}
+	userDir, err := resolver.UserDir(appName)
+	if err != nil {
+		return "", err
+	}
+
+	names := strings.Split(cfgFile, ",")
+	var found string
+	for _, name := range names {
+		path := filepath.Join(userDir, name)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("%w %s and %s", cfg.ErrCfgConflict, found, path)
+		}
+		found = path
+	}
+	if found == "" {
+		found = filepath.Join(userDir, names[0])
+	}
+	return found, nil
+}
+
+func explain(args []string) error {
+	f := newLoadFlags("explain")
+	positional, err := f.parse(args, 1)
+	if err != nil {
+		return err
+	}
+
+	loader, err := f.loader()
+	if err != nil {
+		return err
+	}
+	explanation, err := loader.ExplainKey(*f.appName, *f.cfgFile, f.envs, positional[0])
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range explanation.Candidates {
+		mark := " "
+		if candidate.Source == explanation.Source {
+			mark = "*"
+		}
+
+		var value string
+		switch {
+		case !candidate.Exists:
+			value = "(no file)"
+		case !candidate.Found:
+			value = "(not set)"
+		default:
+			value = formatValue(candidate.Value)
+		}
+		fmt.Printf("%s %s: %s\n", mark, candidate.Source, value)
+	}
+
+	if !explanation.Found {
+		fmt.Printf("%s is not set\n", explanation.Key)
+	} else if explanation.Source == "" {
+		fmt.Printf("%s = %s (merged from several sources)\n", explanation.Key, formatValue(explanation.Value))
+	} else {
+		fmt.Printf("%s = %s from %s\n", explanation.Key, formatValue(explanation.Value), explanation.Source)
+	}
+	return nil
+}
+
+func formatValue(value any) string {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	text := strings.TrimSpace(string(data))
+	if strings.Contains(text, "\n") {
+		return "\n    " + strings.ReplaceAll(text, "\n", "\n    ")
+	}
+	return text
+}
+
+// encrypt encrypts the args or in, one trailing newline of in is trimmed so
+// `echo pw | cfgctl encrypt` encrypts pw, unless -raw is set.
+func encrypt(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	pubPath := flags.String("pub", "", "PEM encoded EC public key")
+	raw := flags.Bool("raw", false, "encrypt stdin byte for byte, with its trailing newline")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *pubPath == "" {
+		return fmt.Errorf("-pub is required")
+	}
+
+	pubFile, err := os.Open(*pubPath)
+	if err != nil {
+		return err
+	}
+	defer pubFile.Close()
+
+	pub, err := security.ReadPublicKey(pubFile)
+	if err != nil {
+		return err
+	}
+
+	var value []byte
+	if flags.NArg() > 0 {
+		value = []byte(strings.Join(flags.Args(), " "))
+	} else if value, err = io.ReadAll(in); err != nil {
+		return err
+	} else if !*raw {
+		value = trimNewline(value)
+	}
+
+	secret, err := cfg.EncryptSecret(pub, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s %s\n", cfg.SecretTag, secret)
+	return err
+}
+
+// trimNewline trims one trailing \n or \r\n.
+func trimNewline(value []byte) []byte {
+	if trimmed, ok := bytes.CutSuffix(value, []byte("\r\n")); ok {
+		return trimmed
+	}
+	return bytes.TrimSuffix(value, []byte("\n"))
+}
+
+func sign(args []string) error {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := flags.String("key", "", "PEM encoded EC private key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *keyPath == "" {
+		return fmt.Errorf("-key is required")
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("expected config files to sign")
+	}
+
+	for _, path := range flags.Args() {
+		if err := cfg.SignCfgWithKeyFile(path, *keyPath); err != nil {
+			return err
+		}
+		fmt.Printf("signed %s to %s\n", path, path+cfg.SignatureSuffix)
+	}
+	return nil
+}
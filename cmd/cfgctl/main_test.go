@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/cfg"
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+func TestUserCfgPath(t *testing.T) {
+	dir := t.TempDir()
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"XDG_CONFIG_HOME": dir})
+
+	path, err := userCfgPath("my-app", "config.yaml", envs)
+	if err != nil || path != filepath.Join(dir, "my-app", "config.yaml") {
+		t.Errorf("unexpected path %s, %v", path, err)
+	}
+
+	t.Chdir(t.TempDir())
+	names := "my-app.yaml,my-app.json"
+	if path, err = userCfgPath("my-app", names, envs); err != nil || path != filepath.Join(dir, "my-app", "my-app.yaml") {
+		t.Errorf("expected the first name, got %s, %v", path, err)
+	}
+	jsonPath := filepath.Join(dir, "my-app", "my-app.json")
+	if err = os.MkdirAll(filepath.Dir(jsonPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(jsonPath, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err = userCfgPath("my-app", names, envs); err != nil || path != jsonPath {
+		t.Errorf("expected the existing name, got %s, %v", path, err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "my-app", "my-app.yaml"), []byte("a: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = userCfgPath("my-app", names, envs); !errors.Is(err, cfg.ErrCfgConflict) {
+		t.Errorf("expected ErrCfgConflict, got %v", err)
+	}
+
+	envs = env.NewReadEnv(envs, map[string]string{"MY_APP_CONFIG": "/tmp/override.yaml"})
+	if path, err = userCfgPath("my-app", "config.yaml", envs); err != nil || path != "/tmp/override.yaml" {
+		t.Errorf("expected override, got %s, %v", path, err)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	testCases := map[string]string{
+		"8080":          "key: 8080\n",
+		"1.5":           "key: 1.5\n",
+		"true":          "key: true\n",
+		"text":          "key: text\n",
+		`"8080"`:        "key: \"8080\"\n",
+		"[a, b]":        "key: [a, b]\n",
+		"{port: 80}":    "key: {port: 80}\n",
+		"":              "key: \"\"\n",
+		"null":          "key: \"null\"\n",
+		"~":             "key: \"~\"\n",
+		"a: b: c":       "key: 'a: b: c'\n",
+		"2024-01-01":    "key: 2024-01-01\n",
+		"!secret abc==": "key: !secret abc==\n",
+	}
+	for s, expected := range testCases {
+		editor, err := cfg.OpenEditor(filepath.Join(t.TempDir(), "config.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = editor.Set("key", parseValue(s)); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if data, err := editor.Bytes(); err != nil || string(data) != expected {
+			t.Errorf("%q: expected %q, got %q, %v", s, expected, data, err)
+		}
+	}
+}
+
+func TestCfgFlags_Loader(t *testing.T) {
+	key, err := security.GenerateECKeyPair("secp256r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := new(bytes.Buffer)
+	if err = security.WriteECPrivateKey(key, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	if err = os.WriteFile(keyPath, keyPEM.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := cfg.EncryptSecret(&key.PublicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err = os.WriteFile(path, []byte("password: !secret "+password+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"APP_CONFIG": path,
+		"APP_KEY":    keyPEM.String(),
+	})
+
+	for _, args := range [][]string{{"-app", "app", "-key", keyPath}, {"-app", "app", "-key-env", "APP_KEY"}} {
+		f := newLoadFlags("print")
+		f.envs = envs
+		if _, err = f.parse(args, 0); err != nil {
+			t.Fatal(err)
+		}
+		loader, err := f.loader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		layered, err := loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if value, _ := layered.Get("password"); value != "s3cret" {
+			t.Errorf("%v: unexpected password %v", args, value)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-app", "app", "-key", keyPath, "-key-env", "APP_KEY"},
+		{"-app", "app", "-key-env", "MISSING"},
+		{"-app", "app", "-key", filepath.Join(dir, "missing.pem")},
+	} {
+		f := newLoadFlags("print")
+		f.envs = envs
+		if _, err = f.parse(args, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.loader(); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestCfgFlags_WalkParents(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "project", "sub")
+	if err := os.MkdirAll(filepath.Join(dir, "project", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(curDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "project", "config.yaml"), []byte("name: project\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("name: outside\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(curDir)
+
+	f := newLoadFlags("get")
+	f.envs = env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"XDG_CONFIG_HOME": filepath.Join(dir, "home")})
+	if _, err := f.parse([]string{"-app", "app", "-walk-parents", "name"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := f.loader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layered, err := loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := layered.Get("name"); value != "project" {
+		t.Errorf("expected the project config, got %v", value)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key, err := security.GenerateECKeyPair("secp256r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := new(bytes.Buffer)
+	if err = security.WritePublicKey(&key.PublicKey, pubPEM); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	pubPath := filepath.Join(dir, "pub.pem")
+	if err = os.WriteFile(pubPath, pubPEM.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{[]string{"-pub", pubPath}, "pw\n", "pw"},
+		{[]string{"-pub", pubPath}, "pw\r\n", "pw"},
+		{[]string{"-pub", pubPath}, "pw\n\n", "pw\n"},
+		{[]string{"-pub", pubPath, "-raw"}, "pw\n", "pw\n"},
+		{[]string{"-pub", pubPath, "two", "words"}, "ignored", "two words"},
+	}
+	for _, tc := range testCases {
+		out := new(bytes.Buffer)
+		if err = encrypt(tc.args, strings.NewReader(tc.stdin), out); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+
+		path := filepath.Join(dir, "config.yaml")
+		if err = os.WriteFile(path, []byte("password: "+out.String()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		loader := cfg.NewLoader(path)
+		loader.Secrets = cfg.NewECIESSecrets(key)
+		var config struct {
+			Password string `yaml:"password"`
+		}
+		if _, err = loader.Load(&config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Password != tc.expected {
+			t.Errorf("%v %q: expected %q, got %q", tc.args, tc.stdin, tc.expected, config.Password)
+		}
+	}
+
+	if err = encrypt(nil, strings.NewReader("pw"), new(bytes.Buffer)); err == nil {
+		t.Error("expected error without -pub")
+	}
+}
+
+func TestCfgFlags_Names(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	jsonPath := filepath.Join(dir, "app.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"name": "json"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newLoadFlags("get")
+	f.envs = env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"XDG_CONFIG_HOME": filepath.Join(dir, "home")})
+	if _, err := f.parse([]string{"-app", "app", "-file", "app.yaml,app.json", "name"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := f.loader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layered, err := loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source, _ := layered.Origin("name"); source != jsonPath {
+		t.Errorf("expected name from %s, got %s", jsonPath, source)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("name: yaml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loader.LoadLayered(*f.appName, *f.cfgFile, f.envs, nil); !errors.Is(err, cfg.ErrCfgConflict) {
+		t.Errorf("expected ErrCfgConflict, got %v", err)
+	}
+}
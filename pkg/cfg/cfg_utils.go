@@ -7,6 +7,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// GetCfgPath returns the config paths in the current dir, ~/.config/<app> and /etc/<app>.
+// It exits if the home or current dir is unavailable. ResolveCfgPath returns an
+// error instead, a PathResolver with WalkParents also searches the parents of
+// the current dir.
 func GetCfgPath(appName string, cfgFile string) []string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -0,0 +1,279 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DescTag is the struct tag with the description of a config key.
+const DescTag = "desc"
+
+// FieldDoc documents a key of a config struct. Keys of slice items are written
+// as key[].name, keys of map values as key.<name>.name.
+type FieldDoc struct {
+	Key      string
+	Type     string
+	Default  string
+	Required bool
+	Rules    string
+	Desc     string
+}
+
+// DescribeCfg returns the documentation of the keys of the config struct cfg,
+// which is a struct, a pointer to one or a reflect.Type, in field order.
+func DescribeCfg(cfg any) []FieldDoc {
+	var docs []FieldDoc
+	describeStruct(structType(cfg), "", &docs, nil)
+	return docs
+}
+
+// GenerateSample returns a sample YAML config of cfg with the defaults set, the
+// descriptions, required markers and rules are written as comments.
+func GenerateSample(cfg any) ([]byte, error) {
+	t := structType(cfg)
+	if t == nil {
+		return nil, fmt.Errorf("config must be a struct, got %T", cfg)
+	}
+
+	root, err := sampleNode(t, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateMarkdown returns a Markdown table of the keys of cfg.
+func GenerateMarkdown(cfg any) string {
+	var sb strings.Builder
+	sb.WriteString("| Key | Type | Default | Required | Validation | Description |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, doc := range DescribeCfg(cfg) {
+		required := ""
+		if doc.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s | %s |\n", doc.Key, escapeCell(doc.Type),
+			codeCell(doc.Default), required, codeCell(doc.Rules), escapeCell(doc.Desc))
+	}
+	return sb.String()
+}
+
+func structType(cfg any) reflect.Type {
+	t, ok := cfg.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(cfg)
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+func describeStruct(t reflect.Type, path string, docs *[]FieldDoc, visiting []reflect.Type) {
+	if t == nil || slices.Contains(visiting, t) {
+		return
+	}
+	visiting = append(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, inline, skip := yamlFieldName(field)
+		if !field.IsExported() || skip {
+			continue
+		}
+		if inline {
+			if ft := derefType(field.Type); ft.Kind() == reflect.Struct {
+				describeStruct(ft, path, docs, visiting)
+			}
+			continue
+		}
+
+		key := joinKey(path, name)
+		rules, _ := parseRules(field.Tag.Get(ValidateTag))
+		*docs = append(*docs, FieldDoc{
+			Key:      key,
+			Type:     typeName(field.Type),
+			Default:  field.Tag.Get(DefaultTag),
+			Required: rules.required,
+			Rules:    otherRules(field.Tag.Get(ValidateTag)),
+			Desc:     field.Tag.Get(DescTag),
+		})
+
+		switch ft := derefType(field.Type); ft.Kind() {
+		case reflect.Struct:
+			describeStruct(ft, key, docs, visiting)
+		case reflect.Slice, reflect.Array:
+			describeStruct(structElem(ft.Elem()), key+"[]", docs, visiting)
+		case reflect.Map:
+			describeStruct(structElem(ft.Elem()), key+".<name>", docs, visiting)
+		}
+	}
+}
+
+// sampleNode returns the sample of the struct type t as a mapping node.
+func sampleNode(t reflect.Type, visiting []reflect.Type) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if slices.Contains(visiting, t) {
+		return node, nil
+	}
+	visiting = append(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, inline, skip := yamlFieldName(field)
+		if !field.IsExported() || skip {
+			continue
+		}
+		if inline {
+			if ft := derefType(field.Type); ft.Kind() == reflect.Struct {
+				inlined, err := sampleNode(ft, visiting)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, inlined.Content...)
+			}
+			continue
+		}
+
+		value, err := sampleValue(field, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name, HeadComment: fieldComment(field)}
+		node.Content = append(node.Content, keyNode, value)
+	}
+	return node, nil
+}
+
+func sampleValue(field reflect.StructField, visiting []reflect.Type) (*yaml.Node, error) {
+	if def, ok := field.Tag.Lookup(DefaultTag); ok {
+		doc := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(def), doc); err != nil {
+			return nil, fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		if len(doc.Content) > 0 {
+			return doc.Content[0], nil
+		}
+	}
+
+	switch ft := derefType(field.Type); ft.Kind() {
+	case reflect.Struct:
+		if structElem(ft) != nil {
+			return sampleNode(ft, visiting)
+		}
+	case reflect.Slice, reflect.Array:
+		if elem := structElem(ft.Elem()); elem != nil {
+			item, err := sampleNode(elem, visiting)
+			if err != nil {
+				return nil, err
+			}
+			return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}, nil
+		}
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}, nil
+	case reflect.Map:
+		if elem := structElem(ft.Elem()); elem != nil {
+			item, err := sampleNode(elem, visiting)
+			if err != nil {
+				return nil, err
+			}
+			return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "<name>"}, item,
+			}}, nil
+		}
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(reflect.Zero(derefType(field.Type)).Interface()); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// fieldComment returns the description, required marker and rules of the field.
+func fieldComment(field reflect.StructField) string {
+	var lines []string
+	if desc := field.Tag.Get(DescTag); desc != "" {
+		lines = append(lines, desc)
+	}
+	if rules, _ := parseRules(field.Tag.Get(ValidateTag)); rules.required {
+		lines = append(lines, "Required.")
+	}
+	if rules := otherRules(field.Tag.Get(ValidateTag)); rules != "" {
+		lines = append(lines, "Validation: "+rules)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// otherRules returns the validate tag without the required rule.
+func otherRules(tag string) string {
+	var rules []string
+	for _, rule := range strings.Split(tag, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" && rule != "required" {
+			rules = append(rules, rule)
+		}
+	}
+	return strings.Join(rules, ",")
+}
+
+func typeName(t reflect.Type) string {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.PkgPath() == "time" {
+			return t.String()
+		}
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "[]" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case reflect.Interface:
+		return "any"
+	}
+	return t.String()
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// structElem returns the struct type of a slice or map element, or nil.
+func structElem(t reflect.Type) reflect.Type {
+	if t = derefType(t); t.Kind() == reflect.Struct && t.PkgPath() != "time" {
+		return t
+	}
+	return nil
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+func codeCell(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + escapeCell(s) + "`"
+}
@@ -0,0 +1,141 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDocServerCfg struct {
+	Host    string        `yaml:"host" default:"localhost" desc:"Host to listen on"`
+	Port    int           `yaml:"port" default:"8080" validate:"min=1,max=65535" desc:"Port to listen on"`
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+}
+
+type testDocCfg struct {
+	Name     string                       `yaml:"name" validate:"required" desc:"Name of the app"`
+	Level    string                       `yaml:"level" default:"info" validate:"oneof=debug info warn" desc:"Log level | verbosity"`
+	Tags     []string                     `yaml:"tags"`
+	Server   testDocServerCfg             `yaml:"server" desc:"HTTP server"`
+	Backends []testDocServerCfg           `yaml:"backends"`
+	Named    map[string]*testDocServerCfg `yaml:"named"`
+	Ignored  string                       `yaml:"-"`
+	internal string
+}
+
+func TestDescribeCfg(t *testing.T) {
+	docs := DescribeCfg(&testDocCfg{})
+
+	var keys []string
+	for _, doc := range docs {
+		keys = append(keys, doc.Key)
+	}
+	expected := "name level tags server server.host server.port server.timeout backends backends[].host " +
+		"backends[].port backends[].timeout named named.<name>.host named.<name>.port named.<name>.timeout"
+	if strings.Join(keys, " ") != expected {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if doc := docs[0]; !doc.Required || doc.Rules != "" || doc.Desc != "Name of the app" || doc.Type != "string" {
+		t.Errorf("unexpected doc %+v", doc)
+	}
+	if doc := docs[5]; doc.Default != "8080" || doc.Rules != "min=1,max=65535" || doc.Type != "int" {
+		t.Errorf("unexpected doc %+v", doc)
+	}
+	if docs[6].Type != "time.Duration" || docs[7].Type != "[]object" || docs[11].Type != "map[string]object" {
+		t.Errorf("unexpected types %s, %s, %s", docs[6].Type, docs[7].Type, docs[11].Type)
+	}
+	if DescribeCfg("not a struct") != nil {
+		t.Error("expected no docs for a string")
+	}
+}
+
+func TestDescribeCfg_InlineMap(t *testing.T) {
+	type inlineCfg struct {
+		Name  string         `yaml:"name"`
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	docs := DescribeCfg(inlineCfg{})
+	if len(docs) != 1 || docs[0].Key != "name" {
+		t.Errorf("unexpected docs %+v", docs)
+	}
+	if md := GenerateMarkdown(inlineCfg{}); !strings.Contains(md, "| `name` | string |") {
+		t.Errorf("unexpected markdown\n%s", md)
+	}
+	if _, err := GenerateSample(inlineCfg{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateSample(t *testing.T) {
+	data, err := GenerateSample(testDocCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# Name of the app
+# Required.
+name: ""
+# Log level | verbosity
+# Validation: oneof=debug info warn
+level: info
+tags: []
+# HTTP server
+server:
+  # Host to listen on
+  host: localhost
+  # Port to listen on
+  # Validation: min=1,max=65535
+  port: 8080
+  timeout: 5s
+backends:
+  - # Host to listen on
+    host: localhost
+    # Port to listen on
+    # Validation: min=1,max=65535
+    port: 8080
+    timeout: 5s
+named:
+  <name>:
+    # Host to listen on
+    host: localhost
+    # Port to listen on
+    # Validation: min=1,max=65535
+    port: 8080
+    timeout: 5s
+`
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+
+	// the sample is a valid config once the required keys are set
+	var cfg testDocCfg
+	if err = Unmarshal(FormatYAML, data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Timeout != 5*time.Second || cfg.Named["<name>"].Port != 8080 {
+		t.Errorf("unexpected sample config %+v", cfg)
+	}
+
+	if _, err = GenerateSample(42); err == nil {
+		t.Error("expected error for non-struct")
+	}
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	md := GenerateMarkdown(testDocServerCfg{})
+	expected := "| Key | Type | Default | Required | Validation | Description |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `host` | string | `localhost` |  |  | Host to listen on |\n" +
+		"| `port` | int | `8080` |  | `min=1,max=65535` | Port to listen on |\n" +
+		"| `timeout` | time.Duration | `5s` |  |  |  |\n"
+	if md != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, md)
+	}
+
+	if md = GenerateMarkdown(testDocCfg{}); !strings.Contains(md, "| `name` | string |  | yes |  | Name of the app |") ||
+		!strings.Contains(md, `Log level \| verbosity`) {
+		t.Errorf("unexpected markdown\n%s", md)
+	}
+}
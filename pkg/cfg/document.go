@@ -0,0 +1,240 @@
+package cfg
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	IncludeTag = "!include"
+	ConfDir    = "conf.d"
+)
+
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Document is a parsed YAML config with includes, conf.d fragments and ${VAR}
+// references resolved. It keeps the file every node was read from.
+type Document struct {
+	Path string
+	Root *yaml.Node
+
+	files map[*yaml.Node]string
+	read  func(path string) ([]byte, error)
+	// json matches struct fields by their json names, see validateJSON
+	json bool
+}
+
+// LoadDocument parses the YAML config at path, replaces every `!include file`
+// value with the content of file (relative to the including file), merges the
+// conf.d/*.yaml fragments next to path in lexical order and then the profile
+// overlays, if Env is set, replaces ${VAR} in scalars with the value of VAR and
+// decrypts !secret values.
+func (l *Loader) LoadDocument(path string) (*Document, error) {
+	data, err := l.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.loadDocument(path, data)
+}
+
+func (l *Loader) loadDocument(path string, data []byte) (*Document, error) {
+	doc := &Document{
+		Path:  path,
+		files: make(map[*yaml.Node]string),
+		read:  l.readFile,
+	}
+
+	root, err := doc.parse(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	doc.Root = root
+
+	var fragments []string
+	if !l.noConfD {
+		if fragments, err = confDFragments(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+	profiles, err := l.profilePaths(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, overlay := range append(fragments, profiles...) {
+		overlayData, err := l.readFile(overlay)
+		if err != nil {
+			return nil, err
+		}
+		node, err := doc.parse(overlay, overlayData, nil)
+		if err != nil {
+			return nil, err
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: overlay must be a mapping", doc.Position(node))
+		}
+		doc.Root = mergeNodes(doc.Root, node)
+	}
+
+	if l.Env != nil {
+		l.interpolate(doc.Root)
+	}
+	if err = doc.decryptSecrets(doc.Root, "", l.Secrets); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func confDFragments(dir string) ([]string, error) {
+	var fragments []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, ConfDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, matches...)
+	}
+	slices.Sort(fragments)
+	return fragments, nil
+}
+
+// Decode decodes the document into out, which is either a pointer accepted by
+// yaml or a *collection.MapWrapper. Structs get the defaults and are validated
+// by their default and validate tags, errors carry the file:line:column.
+func (d *Document) Decode(out any) error {
+	return decodeInto(out, func(v any) error {
+		if err := d.Root.Decode(v); err != nil {
+			return d.positionTypeError(err)
+		}
+		return d.validate(v)
+	})
+}
+
+// File returns the file the node was read from.
+func (d *Document) File(node *yaml.Node) string {
+	if file, ok := d.files[node]; ok {
+		return file
+	}
+	return d.Path
+}
+
+// Position returns file:line:column of the node.
+func (d *Document) Position(node *yaml.Node) string {
+	return fmt.Sprintf("%s:%d:%d", d.File(node), node.Line, node.Column)
+}
+
+// parse parses the file and resolves its includes, includes holds the files
+// which are currently being included to detect cycles.
+func (d *Document) parse(path string, data []byte, includes []string) (*yaml.Node, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var docNode yaml.Node
+	if err = yaml.Unmarshal(data, &docNode); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+	if docNode.Kind == yaml.DocumentNode && len(docNode.Content) > 0 {
+		root = docNode.Content[0]
+	}
+	d.mark(root, path)
+
+	return root, d.resolveIncludes(root, path, append(includes, absPath))
+}
+
+func (d *Document) mark(node *yaml.Node, path string) {
+	d.files[node] = path
+	for _, child := range node.Content {
+		d.mark(child, path)
+	}
+}
+
+func (d *Document) resolveIncludes(node *yaml.Node, path string, includes []string) error {
+	if node.Tag != IncludeTag {
+		for _, child := range node.Content {
+			if err := d.resolveIncludes(child, path, includes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if node.Kind != yaml.ScalarNode || node.Value == "" {
+		return fmt.Errorf("%s: %s requires a file name", d.Position(node), IncludeTag)
+	}
+
+	includePath := node.Value
+	if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(filepath.Dir(path), includePath)
+	}
+	absPath, err := filepath.Abs(includePath)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(includes, absPath) {
+		return fmt.Errorf("%s: include %s: include cycle %s", d.Position(node), node.Value,
+			strings.Join(append(includes, absPath), " -> "))
+	}
+
+	data, err := d.read(includePath)
+	if err != nil {
+		return fmt.Errorf("%s: include %s: %w", d.Position(node), node.Value, err)
+	}
+	included, err := d.parse(includePath, data, includes)
+	if err != nil {
+		return fmt.Errorf("%s: include %s: %w", d.Position(node), node.Value, err)
+	}
+
+	*node = *included
+	d.files[node] = d.files[included]
+	return nil
+}
+
+// mergeNodes deep-merges the mapping src into dst, values of src win.
+func mergeNodes(dst *yaml.Node, src *yaml.Node) *yaml.Node {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if j := mappingIndex(dst, key.Value); j >= 0 {
+			dst.Content[j+1] = mergeNodes(dst.Content[j+1], value)
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return dst
+}
+
+// mappingIndex returns the index of the key node in the mapping node or -1.
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *Loader) interpolate(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && varPattern.MatchString(node.Value) {
+		node.Value = varPattern.ReplaceAllStringFunc(node.Value, func(s string) string {
+			return l.Env.Get(varPattern.FindStringSubmatch(s)[1])
+		})
+		if node.Style == 0 && node.Tag == "!!str" {
+			// resolve the type of plain scalars again, e.g. port: ${PORT}
+			node.Tag = ""
+		}
+	}
+	for _, child := range node.Content {
+		l.interpolate(child)
+	}
+}
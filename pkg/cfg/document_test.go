@@ -0,0 +1,148 @@
+package cfg
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/collection"
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestLoader_LoadDocumentInclude(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, `
+name: app
+db: !include db/db.yaml
+`)
+	writeTestFile(t, filepath.Join(dir, "db", "db.yaml"), `
+host: localhost
+users: !include users.yaml
+`)
+	writeTestFile(t, filepath.Join(dir, "db", "users.yaml"), "- admin\n- guest\n")
+
+	doc, err := NewLoader().LoadDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var obj map[string]any
+	if err = doc.Decode(&obj); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"name": "app",
+		"db": map[string]any{
+			"host":  "localhost",
+			"users": []any{"admin", "guest"},
+		},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("expected %v, got %v", expected, obj)
+	}
+
+	dbNode := doc.Root.Content[3]
+	if file := doc.File(dbNode.Content[1]); file != filepath.Join(dir, "db", "db.yaml") {
+		t.Errorf("unexpected file %s", file)
+	}
+}
+
+func TestLoader_LoadDocumentIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, "name: app\nother: !include other.yaml\n")
+	writeTestFile(t, filepath.Join(dir, "other.yaml"), "back: !include app.yaml\n")
+
+	_, err := NewLoader().LoadDocument(path)
+	if err == nil {
+		t.Fatal("expect to fail")
+	}
+	t.Log(err)
+	if !strings.HasPrefix(err.Error(), path+":2:8: include other.yaml: ") || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoader_LoadDocumentIncludeMissing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, "name: app\n\nother: !include missing.yaml\n")
+
+	_, err := NewLoader().LoadDocument(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+":3:8: include missing.yaml") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLoader_LoadDocumentConfD(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, `
+server:
+  host: localhost
+  port: 80
+log: info
+`)
+	writeTestFile(t, filepath.Join(dir, "conf.d", "20-port.yaml"), "server:\n  port: 8080\n")
+	writeTestFile(t, filepath.Join(dir, "conf.d", "10-port.yaml"), "server:\n  port: 8000\n")
+	writeTestFile(t, filepath.Join(dir, "conf.d", "30-log.yml"), "log: debug\nextra: true\n")
+	writeTestFile(t, filepath.Join(dir, "conf.d", "ignored.txt"), "log: ignored\n")
+
+	var m collection.MapWrapper
+	if _, err := NewLoader(path).Load(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	var server map[string]any
+	if err := m.Get("server", &server); err != nil {
+		t.Fatal(err)
+	}
+	var log string
+	if err := m.Get("log", &log); err != nil {
+		t.Fatal(err)
+	}
+	if server["port"] != 8080 || server["host"] != "localhost" || log != "debug" || !m.Has("extra") {
+		t.Errorf("unexpected config %v %s", server, log)
+	}
+}
+
+func TestLoader_LoadDocumentInterpolation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, `
+name: ${APP_NAME}-server
+port: ${APP_PORT}
+quoted: "${APP_PORT}"
+price: $5
+`)
+
+	loader := NewLoader(path)
+	loader.Env = env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"APP_NAME": "demo",
+		"APP_PORT": "8080",
+	})
+
+	var obj map[string]any
+	if _, err := loader.Load(&obj); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"name":   "demo-server",
+		"port":   8080,
+		"quoted": "8080",
+		"price":  "$5",
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("expected %v, got %v", expected, obj)
+	}
+
+	// no interpolation without env
+	if _, err := NewLoader(path).Load(&obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj["name"] != "${APP_NAME}-server" {
+		t.Errorf("unexpected name %v", obj["name"])
+	}
+}
@@ -0,0 +1,386 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const BackupSuffix = ".bak"
+
+// Editor edits a YAML config file as a yaml.Node tree, so comments, key order
+// and formatting are kept when it is written back.
+type Editor struct {
+	Path string
+
+	doc    *yaml.Node
+	indent int
+	// blankLines counts the blank lines above keys and sequence items, yaml.v3
+	// drops them.
+	blankLines map[*yaml.Node]int
+}
+
+// OpenEditor parses the YAML config at path, a missing file is edited as an
+// empty mapping.
+func OpenEditor(path string) (*Editor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return newEditor(path, data)
+}
+
+func newEditor(path string, data []byte) (*Editor, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	return &Editor{
+		Path:       path,
+		doc:        doc,
+		indent:     detectIndent(data),
+		blankLines: countBlankLines(doc, data),
+	}, nil
+}
+
+// Get returns the node of the key, keys are dotted paths with optional indexes,
+// e.g. servers[0].host or servers.0.host.
+func (e *Editor) Get(key string) (*yaml.Node, error) {
+	segments, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	node := e.doc.Content[0]
+	for i, segment := range segments {
+		_, child, err := childNode(node, segment)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", strings.Join(segments[:i+1], "."), err)
+		}
+		if child == nil {
+			return nil, fmt.Errorf("key %s not found", strings.Join(segments[:i+1], "."))
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// Set sets the key to value, missing maps are created and a sequence index equal
+// to the length appends. The comments of a replaced value are kept.
+func (e *Editor) Set(key string, value any) error {
+	segments, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	newNode := &yaml.Node{}
+	if err = newNode.Encode(value); err != nil {
+		return fmt.Errorf("key %s: %w", key, err)
+	}
+
+	node := e.doc.Content[0]
+	for i, segment := range segments {
+		index, child, err := childNode(node, segment)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", strings.Join(segments[:i+1], "."), err)
+		}
+
+		if i == len(segments)-1 {
+			if child == nil {
+				appendChild(node, segment, newNode)
+				return nil
+			}
+			newNode.HeadComment = child.HeadComment
+			newNode.LineComment = child.LineComment
+			newNode.FootComment = child.FootComment
+			if n, ok := e.blankLines[child]; ok {
+				e.blankLines[newNode] = n
+			}
+			node.Content[index] = newNode
+			return nil
+		}
+
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			appendChild(node, segment, child)
+		}
+		node = child
+	}
+	return nil
+}
+
+// Delete deletes the key, it returns false if the key doesn't exist.
+func (e *Editor) Delete(key string) (bool, error) {
+	segments, err := splitKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	parent := e.doc.Content[0]
+	if len(segments) > 1 {
+		if parent, err = e.Get(strings.Join(segments[:len(segments)-1], ".")); err != nil {
+			return false, nil
+		}
+	}
+
+	index, child, err := childNode(parent, segments[len(segments)-1])
+	if err != nil || child == nil {
+		return false, nil
+	}
+	if parent.Kind == yaml.MappingNode {
+		parent.Content = append(parent.Content[:index-1], parent.Content[index+1:]...)
+	} else {
+		parent.Content = append(parent.Content[:index], parent.Content[index+1:]...)
+	}
+	return true, nil
+}
+
+func (e *Editor) Bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(e.indent)
+	if err := encoder.Encode(e.doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return e.restoreBlankLines(buf.Bytes()), nil
+}
+
+// Save atomically replaces the file, the previous file is kept with the
+// BackupSuffix.
+func (e *Editor) Save() error {
+	data, err := e.Bytes()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.Path, data)
+}
+
+func appendChild(node *yaml.Node, key string, child *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+	} else {
+		node.Content = append(node.Content, child)
+	}
+}
+
+// childNode returns the index of the value in node.Content for the segment, the
+// child is nil if a mapping doesn't contain the key or the index equals the
+// length of a sequence.
+func childNode(node *yaml.Node, segment string) (int, *yaml.Node, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if i := mappingIndex(node, segment); i >= 0 {
+			return i + 1, node.Content[i+1], nil
+		}
+		return len(node.Content) + 1, nil, nil
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index > len(node.Content) {
+			return 0, nil, fmt.Errorf("invalid index %s for sequence of length %d", segment, len(node.Content))
+		}
+		if index == len(node.Content) {
+			return index, nil, nil
+		}
+		return index, node.Content[index], nil
+	default:
+		return 0, nil, fmt.Errorf("value at line %d is not a mapping or sequence", node.Line)
+	}
+}
+
+// splitKey splits a.b[0].c into a, b, 0 and c.
+func splitKey(key string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(key, ".") {
+		name, indexes, hasIndex := strings.Cut(part, "[")
+		if name == "" && !hasIndex {
+			return nil, fmt.Errorf("invalid key %s", key)
+		}
+		if name != "" {
+			segments = append(segments, name)
+		}
+		if !hasIndex {
+			continue
+		}
+		if !strings.HasSuffix(indexes, "]") {
+			return nil, fmt.Errorf("invalid key %s", key)
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			if index == "" {
+				return nil, fmt.Errorf("invalid key %s", key)
+			}
+			segments = append(segments, index)
+		}
+	}
+	return segments, nil
+}
+
+// countBlankLines returns the number of blank lines above the head comment of
+// the keys and items of block collections. Blank lines in scalars and flow
+// collections don't belong to a key.
+func countBlankLines(doc *yaml.Node, data []byte) map[*yaml.Node]int {
+	lines := strings.Split(string(data), "\n")
+	counts := make(map[*yaml.Node]int)
+	for _, node := range blockItems(doc, nil) {
+		n := 0
+		for i := node.Line - 2 - headCommentLines(node); i >= 0 && i < len(lines) && strings.TrimSpace(lines[i]) == ""; i-- {
+			n++
+		}
+		if n > 0 {
+			counts[node] = n
+		}
+	}
+	return counts
+}
+
+// restoreBlankLines inserts the counted blank lines into the encoded data. The
+// data is parsed again to find the lines of the keys, its tree has the same
+// shape as the edited one.
+func (e *Editor) restoreBlankLines(data []byte) []byte {
+	if len(e.blankLines) == 0 {
+		return data
+	}
+	encoded := &yaml.Node{}
+	if err := yaml.Unmarshal(data, encoded); err != nil {
+		return data
+	}
+	edited, parsed := blockItems(e.doc, nil), blockItems(encoded, nil)
+	if len(edited) != len(parsed) {
+		return data
+	}
+
+	lines := strings.Split(string(data), "\n")
+	inserts := make(map[int]int)
+	for i, node := range edited {
+		n := e.blankLines[node]
+		at := parsed[i].Line - 1 - headCommentLines(parsed[i])
+		for j := at - 1; n > 0 && j >= 0 && strings.TrimSpace(lines[j]) == ""; j-- {
+			n--
+		}
+		if n > 0 && at > 0 {
+			inserts[at] = max(inserts[at], n)
+		}
+	}
+
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(strings.Repeat("\n", inserts[i]))
+		sb.WriteString(line)
+		if i < len(lines)-1 {
+			sb.WriteByte('\n')
+		}
+	}
+	return []byte(sb.String())
+}
+
+// blockItems appends the keys and items of the block collections in node.
+func blockItems(node *yaml.Node, items []*yaml.Node) []*yaml.Node {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			items = blockItems(child, items)
+		}
+	case yaml.MappingNode:
+		if node.Style&yaml.FlowStyle != 0 {
+			break
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			items = append(items, node.Content[i])
+			items = blockItems(node.Content[i+1], items)
+		}
+	case yaml.SequenceNode:
+		if node.Style&yaml.FlowStyle != 0 {
+			break
+		}
+		for _, child := range node.Content {
+			items = append(items, child)
+			items = blockItems(child, items)
+		}
+	}
+	return items
+}
+
+// headCommentLines returns the number of lines of the head comment of node, a
+// collection starting on its line may hold the comment on its first child.
+func headCommentLines(node *yaml.Node) int {
+	if node.HeadComment != "" {
+		return strings.Count(node.HeadComment, "\n") + 1
+	}
+	if (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) > 0 && node.Content[0].Line == node.Line {
+		return headCommentLines(node.Content[0])
+	}
+	return 0
+}
+
+// detectIndent returns the smallest indentation of the file, 2 if it is flat.
+func detectIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || len(trimmed) == len(line) {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent == 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 2 {
+		return 2
+	}
+	return indent
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it to path,
+// the previous file is copied to path + BackupSuffix first.
+func writeFileAtomic(path string, data []byte) error {
+	perm := fs.FileMode(0o644)
+	previous, err := os.ReadFile(path)
+	if err == nil {
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err = os.WriteFile(path+BackupSuffix, previous, perm); err != nil {
+			return fmt.Errorf("failed to backup config file %s: %w", path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
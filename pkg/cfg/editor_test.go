@@ -0,0 +1,264 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const editorTestCfg = `# app config
+name: app # the name
+
+server:
+    # listen port
+    port: 80
+    hosts:
+        - a
+        - b
+log: info
+`
+
+func TestEditor_SetDeleteSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, editorTestCfg)
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	editor, err := OpenEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range map[string]any{
+		"name":            "demo",
+		"server.port":     8080,
+		"server.hosts[1]": "c",
+		"server.hosts.2":  "d",
+		"db.user":         "admin",
+	} {
+		if err = editor.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if deleted, err := editor.Delete("log"); err != nil || !deleted {
+		t.Fatalf("expected log to be deleted, got %v %v", deleted, err)
+	}
+	if deleted, _ := editor.Delete("missing.key"); deleted {
+		t.Fatal("expected missing key not to be deleted")
+	}
+
+	if err = editor.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# app config
+name: demo # the name
+
+server:
+    # listen port
+    port: 8080
+    hosts:
+        - a
+        - c
+        - d
+db:
+    user: admin
+`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	backup, err := os.ReadFile(path + BackupSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != editorTestCfg {
+		t.Errorf("unexpected backup:\n%s", backup)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestEditor_Get(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, editorTestCfg)
+
+	editor, err := OpenEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := editor.Get("server.hosts[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Value != "b" {
+		t.Errorf("expected b, got %s", node.Value)
+	}
+
+	for _, key := range []string{"server.missing", "server.hosts[5]", "name.sub", "server..port", "server.hosts[1"} {
+		if _, err = editor.Get(key); err == nil {
+			t.Errorf("expected %s to fail", key)
+		} else {
+			t.Log(err)
+		}
+	}
+
+	if err = editor.Set("server.hosts[5]", "x"); err == nil {
+		t.Error("expected out of range index to fail")
+	}
+}
+
+func TestEditor_NewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.yaml")
+
+	editor, err := OpenEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = editor.Set("a.b", true); err != nil {
+		t.Fatal(err)
+	}
+	if err = editor.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a:\n  b: true\n" {
+		t.Errorf("unexpected content:\n%s", data)
+	}
+	if _, err = os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Error("expected no backup for a new file")
+	}
+}
+
+func TestEditor_BlockScalar(t *testing.T) {
+	content := `script: |
+  echo a
+
+  echo b
+
+motd: >-
+  hello
+
+  world
+`
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, content)
+
+	editor, err := OpenEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := editor.Get("script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Value != "echo a\n\necho b\n" {
+		t.Errorf("unexpected script %q", node.Value)
+	}
+
+	data, err := editor.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("expected:\n%s\ngot:\n%s", content, data)
+	}
+}
+
+func TestEditor_BlankLines(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+	}{
+		{"quoted", "msg: \"hello\n\n  world\"\n", "msg", "hello\nworld"},
+		{"plain", "msg: one\n\n  two\n", "msg", "one\ntwo"},
+		{"flow", "list: [a,\n\n b]\nserver:\n  hosts: [a,\n\n    b]\n", "server.hosts[1]", "b"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.yaml")
+			writeTestFile(t, path, tc.content)
+
+			editor, err := OpenEditor(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = editor.Set("x", 2); err != nil {
+				t.Fatal(err)
+			}
+			data, err := editor.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := newEditor(path, data)
+			if err != nil {
+				t.Fatalf("%v:\n%s", err, data)
+			}
+			node, err := reopened.Get(tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if node.Value != tc.value {
+				t.Errorf("expected %q, got %q in\n%s", tc.value, node.Value, data)
+			}
+		})
+	}
+
+	content := `# app config
+
+name: app
+
+# server settings
+server:
+  port: 80
+
+  hosts:
+    - a
+
+    - b
+keep: |+
+  text
+
+log: info
+`
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, content)
+	editor, err := OpenEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = editor.Set("server.hosts[1]", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err = editor.Set("server.port", 8080); err != nil {
+		t.Fatal(err)
+	}
+	data, err := editor.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(strings.Replace(content, "- b", "- c", 1), "80", "8080", 1)
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
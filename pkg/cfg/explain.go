@@ -0,0 +1,106 @@
+package cfg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// Candidate is the value of a key in one config source, Exists is false for
+// missing files.
+type Candidate struct {
+	Source string
+	Exists bool
+	Found  bool
+	Value  any
+}
+
+// Explanation shows the effective value of a key and the value in every source.
+type Explanation struct {
+	Key        string
+	Found      bool
+	Value      any
+	Source     string
+	Candidates []Candidate
+}
+
+// ExplainKey returns the value of the dotted key in every config file of
+// ResolveCfgPath, their profile overlays and the <APP>_ variable in precedence
+// order, and the effective value of LoadLayered with the source which supplied
+// it.
+func ExplainKey(appName string, cfgFile string, envs env.Env, key string) (*Explanation, error) {
+	return NewLoader().ExplainKey(appName, cfgFile, envs, key)
+}
+
+// ExplainKey is like the package ExplainKey but loads every file with the
+// options of l, see Loader.LoadLayered.
+func (l *Loader) ExplainKey(appName string, cfgFile string, envs env.Env, key string) (*Explanation, error) {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+
+	layered, err := l.LoadLayered(appName, cfgFile, envs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{Key: key}
+	explanation.Value, explanation.Found = layered.Get(key)
+	explanation.Source, _ = layered.Origin(key)
+
+	envName := envPrefix(appName) + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "__"))
+	envCandidate := Candidate{Source: "env:" + envName, Exists: true}
+	if envs.Contains(envName) {
+		envCandidate.Found = true
+		envCandidate.Value = parseScalar(envs.Get(envName))
+	}
+	explanation.Candidates = append(explanation.Candidates, envCandidate)
+
+	cfgPaths, err := l.cfgPaths(l.resolver(envs), appName, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range cfgPaths {
+		files, layers, err := l.appLoader(appName, envs, path).loadLayers(path)
+		if err != nil {
+			if !errors.Is(err, ErrCfgNotFound) {
+				return nil, err
+			}
+			explanation.Candidates = append(explanation.Candidates, Candidate{Source: path})
+			continue
+		}
+		// profile overlays take precedence over their config file
+		for i := len(files) - 1; i >= 0; i-- {
+			candidate := Candidate{Source: files[i], Exists: true}
+			candidate.Value, candidate.Found = lookupKey(layers[i], key)
+			explanation.Candidates = append(explanation.Candidates, candidate)
+		}
+	}
+	return explanation, nil
+}
+
+// lookupKey returns the value of the dotted key, numeric segments index slices.
+func lookupKey(data map[string]any, key string) (any, bool) {
+	var current any = data
+	for _, segment := range strings.Split(key, ".") {
+		switch value := current.(type) {
+		case map[string]any:
+			child, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = child
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+			current = value[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
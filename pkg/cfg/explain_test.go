@@ -0,0 +1,111 @@
+package cfg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestExplainKey(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "work")
+	userPath := filepath.Join(dir, "home", "explainapp", "config.yaml")
+	curPath := filepath.Join(curDir, "config.yaml")
+	writeTestFile(t, userPath, "server:\n  port: 80\n  host: user.local\n")
+	writeTestFile(t, curPath, "server:\n  port: 8080\n")
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS": filepath.Join(dir, "xdg"),
+	})
+
+	explanation, err := ExplainKey("explainapp", "config.yaml", envs, "server.port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Found || explanation.Value != 8080 || explanation.Source != curPath {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+
+	expected := []Candidate{
+		{Source: "env:EXPLAINAPP_SERVER__PORT", Exists: true},
+		{Source: curPath, Exists: true, Found: true, Value: 8080},
+		{Source: userPath, Exists: true, Found: true, Value: 80},
+		{Source: filepath.Join(dir, "xdg", "explainapp", "config.yaml")},
+		{Source: "/etc/explainapp/config.yaml"},
+	}
+	if len(explanation.Candidates) != len(expected) {
+		t.Fatalf("expected %d candidates, got %+v", len(expected), explanation.Candidates)
+	}
+	for i, candidate := range explanation.Candidates {
+		if candidate != expected[i] {
+			t.Errorf("candidate %d: expected %+v, got %+v", i, expected[i], candidate)
+		}
+	}
+
+	envs = env.NewReadEnv(envs, map[string]string{"EXPLAINAPP_SERVER__HOST": "env.local"})
+	explanation, err = ExplainKey("explainapp", "config.yaml", envs, "server.host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Value != "env.local" || explanation.Source != "env:EXPLAINAPP_SERVER__HOST" {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+	if c := explanation.Candidates[1]; !c.Exists || c.Found {
+		t.Errorf("expected host not to be set in %s, got %+v", curPath, c)
+	}
+}
+
+func TestLookupKey(t *testing.T) {
+	data := map[string]any{
+		"servers": []any{
+			map[string]any{"host": "a"},
+			map[string]any{"host": "b"},
+		},
+	}
+
+	if value, ok := lookupKey(data, "servers.1.host"); !ok || value != "b" {
+		t.Errorf("unexpected value %v", value)
+	}
+	for _, key := range []string{"servers.2.host", "servers.x", "servers.0.host.name", "missing"} {
+		if _, ok := lookupKey(data, key); ok {
+			t.Errorf("expected %s not to be found", key)
+		}
+	}
+}
+
+func TestExplainKey_Profile(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "config.yaml")
+	devPath := filepath.Join(dir, "config.dev.yaml")
+	writeTestFile(t, basePath, "server:\n  port: 80\n")
+	writeTestFile(t, devPath, "server:\n  port: 8080\n")
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		OverrideEnvName("explainapp"): basePath,
+		ProfileEnvName("explainapp"):  "dev",
+	})
+	explanation, err := ExplainKey("explainapp", "config.yaml", envs, "server.port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Value != 8080 || explanation.Source != devPath {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+
+	expected := []Candidate{
+		{Source: "env:EXPLAINAPP_SERVER__PORT", Exists: true},
+		{Source: devPath, Exists: true, Found: true, Value: 8080},
+		{Source: basePath, Exists: true, Found: true, Value: 80},
+	}
+	if len(explanation.Candidates) != len(expected) {
+		t.Fatalf("expected %d candidates, got %+v", len(expected), explanation.Candidates)
+	}
+	for i, candidate := range explanation.Candidates {
+		if candidate != expected[i] {
+			t.Errorf("candidate %d: expected %+v, got %+v", i, expected[i], candidate)
+		}
+	}
+}
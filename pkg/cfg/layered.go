@@ -0,0 +1,273 @@
+package cfg
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/collection"
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+const OverrideSource = "override"
+
+// Layered is a config deep-merged from several sources, it records for every
+// leaf key which source supplied the final value.
+type Layered struct {
+	data    map[string]any
+	origins map[string]string
+}
+
+func NewLayered() *Layered {
+	return &Layered{
+		data:    make(map[string]any),
+		origins: make(map[string]string),
+	}
+}
+
+// LoadLayered merges the config files of ResolveCfgPath with their active profile
+// overlays from the lowest to the highest precedence (/etc/<app> <
+// $XDG_CONFIG_DIRS/<app> < ~/.config/<app> < current dir), then the <APP>_
+// environment variables and finally the overrides.
+// Overrides are keyed by dotted paths, e.g. server.port.
+func LoadLayered(appName string, cfgFile string, envs env.Env, overrides map[string]any) (*Layered, error) {
+	return NewLoader().LoadLayered(appName, cfgFile, envs, overrides)
+}
+
+// LoadLayered is like the package LoadLayered but loads every file with the
+// options of l, e.g. Secrets, Perm and TrustedKey, and resolves the config
+// paths with Resolver and Names. Paths is ignored and Profiles defaults to
+// ActiveProfiles.
+func (l *Loader) LoadLayered(appName string, cfgFile string, envs env.Env, overrides map[string]any) (*Layered, error) {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+
+	cfgPaths, err := l.cfgPaths(l.resolver(envs), appName, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	layered := NewLayered()
+	for _, path := range slices.Backward(cfgPaths) {
+		files, layers, err := l.appLoader(appName, envs, path).loadLayers(path)
+		if err != nil {
+			if errors.Is(err, ErrCfgNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		for i, file := range files {
+			layered.Merge(file, layers[i])
+		}
+	}
+
+	layered.MergeEnv(appName, envs)
+
+	for _, key := range slices.Sorted(maps.Keys(overrides)) {
+		layered.Set(key, overrides[key], OverrideSource)
+	}
+	return layered, nil
+}
+
+// appLoader returns a copy of l for the config file of the app at path.
+func (l *Loader) appLoader(appName string, envs env.Env, path string) *Loader {
+	loader := *l
+	loader.Paths = []string{path}
+	if loader.Profiles == nil {
+		loader.Profiles = ActiveProfiles(appName, envs)
+	}
+	return &loader
+}
+
+// loadLayers loads the config file with its conf.d fragments and each of its
+// profile overlays on its own, so the overlay is the source of the values it
+// supplies. The files are returned in the order they are merged, ErrCfgNotFound
+// if path doesn't exist.
+func (l *Loader) loadLayers(path string) ([]string, []map[string]any, error) {
+	base := *l
+	base.Paths = []string{path}
+	base.Profiles = nil
+	if _, err := base.Find(); err != nil {
+		return nil, nil, err
+	}
+	overlays, err := l.profilePaths(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := append([]string{path}, overlays...)
+	layers := make([]map[string]any, len(files))
+	for i, file := range files {
+		fileLoader := base
+		if i > 0 {
+			// only the config file itself is versioned, and the fragments are
+			// merged below the overlays
+			fileLoader.Migrations = nil
+			fileLoader.noConfD = true
+		}
+		if err = fileLoader.LoadFile(file, &layers[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, layers, nil
+}
+
+// Merge deep-merges data on top of the config, values of data win. Maps are
+// merged key by key, any other value replaces the previous one.
+func (l *Layered) Merge(source string, data map[string]any) {
+	l.merge(l.data, data, "", source)
+}
+
+// MergeEnv merges the variables named <APP>_<KEY>, a double underscore in KEY
+// separates nested keys, e.g. MYAPP_SERVER__PORT sets server.port. Each part of
+// KEY matches an existing key case-insensitively, e.g. MYAPP_LOGLEVEL sets
+// logLevel, new keys are lower case. Values are parsed as YAML scalars.
+func (l *Layered) MergeEnv(appName string, envs env.Env) {
+	l.mergeEnv(appName, envs, "")
+}
+
+// mergeEnv is MergeEnv with source as the origin of the values, or the name of
+// the variable if source is empty.
+func (l *Layered) mergeEnv(appName string, envs env.Env, source string) {
+	prefix := envPrefix(appName) + "_"
+	all := envs.GetAll()
+	for _, name := range slices.Sorted(maps.Keys(all)) {
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) ||
+			name == OverrideEnvName(appName) || name == ProfileEnvName(appName) {
+			continue
+		}
+		origin := source
+		if origin == "" {
+			origin = "env:" + name
+		}
+		key := l.envKey(strings.Split(name[len(prefix):], "__"))
+		l.Set(key, parseScalar(all[name]), origin)
+	}
+}
+
+// envKey returns the dotted key of the parts of a variable name, see MergeEnv.
+func (l *Layered) envKey(parts []string) string {
+	keys := make([]string, len(parts))
+	current := l.data
+	for i, part := range parts {
+		keys[i] = strings.ToLower(part)
+		if _, ok := current[keys[i]]; !ok {
+			for _, key := range slices.Sorted(maps.Keys(current)) {
+				if strings.EqualFold(key, part) {
+					keys[i] = key
+					break
+				}
+			}
+		}
+		current, _ = current[keys[i]].(map[string]any)
+	}
+	return strings.Join(keys, ".")
+}
+
+// Set sets the dotted key, intermediate maps are created as needed.
+func (l *Layered) Set(key string, value any, source string) {
+	parts := strings.Split(key, ".")
+	current := l.data
+	for i, part := range parts[:len(parts)-1] {
+		child, ok := current[part].(map[string]any)
+		if !ok {
+			l.clearOrigins(strings.Join(parts[:i+1], "."))
+			child = make(map[string]any)
+			current[part] = child
+		}
+		current = child
+	}
+	l.merge(current, map[string]any{parts[len(parts)-1]: value}, strings.Join(parts[:len(parts)-1], "."), source)
+}
+
+func (l *Layered) merge(dst map[string]any, src map[string]any, prefix string, source string) {
+	for k, v := range src {
+		key := joinKey(prefix, k)
+		if srcMap, ok := v.(map[string]any); ok {
+			dstMap, ok := dst[k].(map[string]any)
+			if !ok {
+				l.clearOrigins(key)
+				dstMap = make(map[string]any, len(srcMap))
+				dst[k] = dstMap
+				if len(srcMap) == 0 {
+					l.origins[key] = source
+				}
+			}
+			l.merge(dstMap, srcMap, key, source)
+			continue
+		}
+
+		l.clearOrigins(key)
+		dst[k] = v
+		l.origins[key] = source
+	}
+}
+
+func (l *Layered) clearOrigins(key string) {
+	delete(l.origins, key)
+	for k := range l.origins {
+		if strings.HasPrefix(k, key+".") {
+			delete(l.origins, k)
+		}
+	}
+}
+
+// Get returns the value of the dotted key.
+func (l *Layered) Get(key string) (any, bool) {
+	return lookupKey(l.data, key)
+}
+
+// Origin returns the source which supplied the value of the dotted leaf key.
+func (l *Layered) Origin(key string) (string, bool) {
+	source, ok := l.origins[key]
+	return source, ok
+}
+
+// Origins returns the source of every leaf key.
+func (l *Layered) Origins() map[string]string {
+	return maps.Clone(l.origins)
+}
+
+// Keys returns the sorted dotted leaf keys.
+func (l *Layered) Keys() []string {
+	return slices.Sorted(maps.Keys(l.origins))
+}
+
+func (l *Layered) Data() map[string]any {
+	return l.data
+}
+
+func (l *Layered) Map() *collection.MapWrapper {
+	return collection.NewMapWrapper(l.data)
+}
+
+// Decode decodes the merged config into out like yaml.Unmarshal.
+func (l *Layered) Decode(out any) error {
+	data, err := yaml.Marshal(l.data)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func parseScalar(s string) any {
+	var value any
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	switch value.(type) {
+	case map[string]any, []any, nil:
+		return s
+	}
+	return value
+}
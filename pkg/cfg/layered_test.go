@@ -0,0 +1,274 @@
+package cfg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+func TestLayered_Merge(t *testing.T) {
+	l := NewLayered()
+	l.Merge("base", map[string]any{
+		"name": "base",
+		"server": map[string]any{
+			"host": "localhost",
+			"port": 80,
+		},
+		"tags": []any{"a"},
+	})
+	l.Merge("top", map[string]any{
+		"server": map[string]any{
+			"port": 8080,
+		},
+		"tags": []any{"b", "c"},
+	})
+
+	expected := map[string]any{
+		"name": "base",
+		"server": map[string]any{
+			"host": "localhost",
+			"port": 8080,
+		},
+		"tags": []any{"b", "c"},
+	}
+	if !reflect.DeepEqual(l.Data(), expected) {
+		t.Errorf("expected %v, got %v", expected, l.Data())
+	}
+
+	expectedOrigins := map[string]string{
+		"name":        "base",
+		"server.host": "base",
+		"server.port": "top",
+		"tags":        "top",
+	}
+	if !reflect.DeepEqual(l.Origins(), expectedOrigins) {
+		t.Errorf("expected %v, got %v", expectedOrigins, l.Origins())
+	}
+}
+
+func TestLayered_ReplaceMapWithScalar(t *testing.T) {
+	l := NewLayered()
+	l.Merge("base", map[string]any{"server": map[string]any{"port": 80}})
+	l.Set("server", "disabled", OverrideSource)
+
+	if _, ok := l.Origin("server.port"); ok {
+		t.Error("expected server.port origin to be removed")
+	}
+	if source, _ := l.Origin("server"); source != OverrideSource {
+		t.Errorf("expected override, got %s", source)
+	}
+
+	l.Set("server.port", 90, "again")
+	if !reflect.DeepEqual(l.Keys(), []string{"server.port"}) {
+		t.Errorf("unexpected keys %v", l.Keys())
+	}
+}
+
+func TestLayered_MergeEnvCamelCase(t *testing.T) {
+	l := NewLayered()
+	l.Merge("file", map[string]any{
+		"logLevel": "info",
+		"server":   map[string]any{"maxConns": 10},
+	})
+	l.MergeEnv("zz", env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"ZZ_LOGLEVEL":          "debug",
+		"ZZ_SERVER__MAXCONNS":  "20",
+		"ZZ_SERVER__READ_SIZE": "4096",
+	}))
+
+	expected := map[string]any{
+		"logLevel": "debug",
+		"server":   map[string]any{"maxConns": 20, "read_size": 4096},
+	}
+	if !reflect.DeepEqual(l.Data(), expected) {
+		t.Errorf("expected %v, got %v", expected, l.Data())
+	}
+	if source, _ := l.Origin("logLevel"); source != "env:ZZ_LOGLEVEL" {
+		t.Errorf("unexpected origin %s", source)
+	}
+
+	var cfg struct {
+		LogLevel string `yaml:"logLevel"`
+	}
+	if err := l.Decode(&cfg); err != nil || cfg.LogLevel != "debug" {
+		t.Errorf("unexpected config %+v, %v", cfg, err)
+	}
+}
+
+func TestLoadLayered(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "work")
+	writeTestFile(t, filepath.Join(dir, "xdg", "layerapp", "config.yaml"), `
+name: system
+log:
+  level: info
+  file: /var/log/app.log
+server:
+  port: 80
+`)
+	writeTestFile(t, filepath.Join(dir, "home", ".config", "layerapp", "config.yaml"), `
+log:
+  level: debug
+server:
+  host: home.local
+`)
+	writeTestFile(t, filepath.Join(curDir, "config.yaml"), `
+server:
+  host: cur.local
+`)
+	writeTestFile(t, filepath.Join(curDir, "config.dev.yaml"), `
+server:
+  host: dev.local
+`)
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"HOME":                  filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS":       filepath.Join(dir, "xdg"),
+		"LAYERAPP_SERVER__PORT": "9090",
+		"OTHER_SERVER__PORT":    "1",
+	})
+
+	l, err := LoadLayered("layerapp", "config.yaml", envs, map[string]any{"log.level": "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg struct {
+		Name string
+		Log  struct {
+			Level string
+			File  string
+		}
+		Server struct {
+			Host string
+			Port int
+		}
+	}
+	if err := l.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "system" || cfg.Log.Level != "warn" || cfg.Log.File != "/var/log/app.log" ||
+		cfg.Server.Host != "cur.local" || cfg.Server.Port != 9090 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	expectedOrigins := map[string]string{
+		"name":        filepath.Join(dir, "xdg", "layerapp", "config.yaml"),
+		"log.file":    filepath.Join(dir, "xdg", "layerapp", "config.yaml"),
+		"log.level":   OverrideSource,
+		"server.host": filepath.Join(curDir, "config.yaml"),
+		"server.port": "env:LAYERAPP_SERVER__PORT",
+	}
+	if !reflect.DeepEqual(l.Origins(), expectedOrigins) {
+		t.Errorf("expected %v, got %v", expectedOrigins, l.Origins())
+	}
+}
+
+func TestLoadLayered_Profile(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "config.yaml")
+	devPath := filepath.Join(dir, "config.dev.yaml")
+	writeTestFile(t, basePath, "name: app\nserver:\n  host: cur.local\n  port: 80\n")
+	writeTestFile(t, devPath, "server:\n  host: dev.local\n")
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		OverrideEnvName("layerapp"): basePath,
+		ProfileEnvName("layerapp"):  "dev",
+	})
+	l, err := LoadLayered("layerapp", "config.yaml", envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{"name": "app", "server": map[string]any{"host": "dev.local", "port": 80}}
+	if !reflect.DeepEqual(l.Data(), expected) {
+		t.Errorf("expected %v, got %v", expected, l.Data())
+	}
+	expectedOrigins := map[string]string{
+		"name":        basePath,
+		"server.host": devPath,
+		"server.port": basePath,
+	}
+	if !reflect.DeepEqual(l.Origins(), expectedOrigins) {
+		t.Errorf("expected %v, got %v", expectedOrigins, l.Origins())
+	}
+}
+
+func TestLoadLayered_ProfileConfD(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "config.yaml")
+	stagingPath := filepath.Join(dir, "config.staging.yaml")
+	writeTestFile(t, basePath, "a: base\nb: base\n")
+	writeTestFile(t, filepath.Join(dir, ConfDir, "10.yaml"), "a: confd\nb: confd\n")
+	writeTestFile(t, stagingPath, "a: staging\n")
+
+	var cfg map[string]any
+	loader := NewLoader(basePath)
+	loader.Profiles = []string{"staging"}
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		OverrideEnvName("layerapp"): basePath,
+		ProfileEnvName("layerapp"):  "staging",
+	})
+	l, err := LoadLayered("layerapp", "config.yaml", envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]any{"a": "staging", "b": "confd"}; !reflect.DeepEqual(l.Data(), expected) || !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %v, got %v and %v", expected, l.Data(), cfg)
+	}
+	if expected := map[string]string{"a": stagingPath, "b": basePath}; !reflect.DeepEqual(l.Origins(), expected) {
+		t.Errorf("expected %v, got %v", expected, l.Origins())
+	}
+
+	explanation, err := ExplainKey("layerapp", "config.yaml", envs, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Value != "staging" || explanation.Source != stagingPath {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+}
+
+func TestLoader_LoadLayeredSecrets(t *testing.T) {
+	key, err := security.GenerateECKeyPair("secp256r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := EncryptSecret(&key.PublicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "db:\n  password: !secret "+password+"\n")
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{OverrideEnvName("layerapp"): path})
+
+	if _, err = LoadLayered("layerapp", "config.yaml", envs, nil); err == nil {
+		t.Fatal("expected error without decrypter")
+	}
+
+	loader := NewLoader()
+	loader.Secrets = NewECIESSecrets(key)
+	l, err := loader.LoadLayered("layerapp", "config.yaml", envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := l.Get("db.password"); value != "s3cret" {
+		t.Errorf("unexpected password %v", value)
+	}
+
+	explanation, err := loader.ExplainKey("layerapp", "config.yaml", envs, "db.password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Value != "s3cret" || explanation.Source != path {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+}
@@ -0,0 +1,274 @@
+package cfg
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/collection"
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+var ErrCfgNotFound = errors.New("config file not found")
+
+// Loader decodes the first existing file of Paths. If Env is set, ${VAR} in
+// YAML configs is replaced with the value of VAR, !secret values are decrypted
+// by Secrets. The overlays of Profiles next to the file are merged in order.
+// If Migrations is set, the file is upgraded to its latest version first and
+// written back if WriteBack is set and TrustedKey isn't. Perm decides what
+// happens to files which fail CheckPerm, e.g. configs with secrets. If
+// TrustedKey is set, every file must have a signature of SignCfg which
+// verifies against it. Resolver finds the config files of an app for LoadApp,
+// LoadLayered and ExplainKey, e.g. with WalkParents set. If Names is set, they
+// discover any of Names in each config dir instead of cfgFile, see
+// PathResolver.Discover.
+type Loader struct {
+	Paths      []string
+	Env        env.Env
+	Secrets    SecretDecrypter
+	Profiles   []string
+	Migrations *Migrations
+	WriteBack  bool
+	Perm       PermPolicy
+	TrustedKey *ecdsa.PublicKey
+	Resolver   *PathResolver
+	Names      []string
+
+	// noConfD skips the conf.d fragments, see loadLayers
+	noConfD bool
+	// onRead is called with every file read, see NewLoaderWatcher
+	onRead func(path string)
+}
+
+func NewLoader(paths ...string) *Loader {
+	return &Loader{Paths: paths}
+}
+
+// LoadCfg decodes the first existing config file returned by ResolveCfgPath into
+// out and returns the path of the file that was used.
+func LoadCfg(appName string, cfgFile string, out any) (string, error) {
+	return NewLoader().LoadApp(appName, cfgFile, out)
+}
+
+// LoadApp is like LoadCfg but loads with the options of l and resolves the
+// config paths with Resolver and Names. Paths is ignored and Profiles
+// defaults to ActiveProfiles.
+func (l *Loader) LoadApp(appName string, cfgFile string, out any) (string, error) {
+	resolver := l.resolver(nil)
+	cfgPaths, err := l.cfgPaths(resolver, appName, cfgFile)
+	if err != nil {
+		return "", err
+	}
+	if len(cfgPaths) == 0 {
+		return "", fmt.Errorf("%w: none of %s in the config dirs of %s", ErrCfgNotFound, strings.Join(l.Names, ", "), appName)
+	}
+
+	loader := *l
+	loader.Paths = cfgPaths
+	if loader.Profiles == nil {
+		loader.Profiles = ActiveProfiles(appName, resolver.Env)
+	}
+	return loader.Load(out)
+}
+
+// cfgPaths returns the config paths of the app in precedence order, the
+// existing files with any of Names if it is set.
+func (l *Loader) cfgPaths(resolver *PathResolver, appName string, cfgFile string) ([]string, error) {
+	if len(l.Names) > 0 {
+		return resolver.Discover(appName, l.Names)
+	}
+	return resolver.Resolve(appName, cfgFile)
+}
+
+// resolver returns a copy of Resolver, or a new PathResolver, which looks up
+// variables in envs if it isn't nil.
+func (l *Loader) resolver(envs env.Env) *PathResolver {
+	var resolver PathResolver
+	if l.Resolver != nil {
+		resolver = *l.Resolver
+	}
+	if envs != nil {
+		resolver.Env = envs
+	}
+	if resolver.Env == nil {
+		resolver.Env = env.OSEnv
+	}
+	return &resolver
+}
+
+// Find returns the first path of Paths which exists and is a regular file.
+func (l *Loader) Find() (string, error) {
+	for _, path := range l.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return "", fmt.Errorf("failed to stat config file %s: %w", path, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("%w in %s", ErrCfgNotFound, strings.Join(l.Paths, ", "))
+}
+
+// Load decodes the first existing config file into out, which is either a
+// pointer accepted by yaml/json or a *collection.MapWrapper.
+func (l *Loader) Load(out any) (string, error) {
+	path, err := l.Find()
+	if err != nil {
+		return "", err
+	}
+	return path, l.LoadFile(path, out)
+}
+
+// LoadFile decodes the config file into out, see Load. Structs get the defaults
+// and are validated by their default and validate tags in YAML and JSON files.
+func (l *Loader) LoadFile(path string, out any) error {
+	data, err := l.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	format := DetectFormat(path, data)
+	if l.Migrations != nil {
+		if data, err = l.migrate(path, format, data); err != nil {
+			return err
+		}
+	}
+
+	if format == FormatYAML {
+		doc, err := l.loadDocument(path, data)
+		if err != nil {
+			return err
+		}
+		return doc.Decode(out)
+	}
+
+	profilePaths, err := l.profilePaths(path)
+	if err != nil {
+		return err
+	}
+	if len(profilePaths) > 0 {
+		if data, err = l.mergeJSONProfiles(path, data, profilePaths); err != nil {
+			return err
+		}
+	}
+
+	if err = Unmarshal(format, data, out); err != nil {
+		return fmt.Errorf("failed to decode config file %s: %w", path, err)
+	}
+	return validateJSON(path, data, out)
+}
+
+// readFile reads a config file after checking it according to Perm, and
+// verifies its signature if TrustedKey is set.
+func (l *Loader) readFile(path string) ([]byte, error) {
+	if l.Perm != PermIgnore {
+		if err := CheckPerm(path); err != nil {
+			if l.Perm == PermRefuse || !errors.Is(err, ErrInsecurePerm) {
+				return nil, err
+			}
+			log.Warnf("%v", err)
+		}
+	}
+
+	if l.onRead != nil {
+		l.onRead(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if l.TrustedKey != nil {
+		if err = VerifyCfg(path, data, l.TrustedKey); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// mergeJSONProfiles deep-merges the overlays into the JSON config.
+func (l *Loader) mergeJSONProfiles(path string, data []byte, profilePaths []string) ([]byte, error) {
+	merged := NewLayered()
+	for i, file := range append([]string{path}, profilePaths...) {
+		if i > 0 {
+			var err error
+			if data, err = l.readFile(file); err != nil {
+				return nil, err
+			}
+		}
+
+		var obj map[string]any
+		if err := Unmarshal(DetectFormat(file, data), data, &obj); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", file, err)
+		}
+		merged.Merge(file, obj)
+	}
+	return json.Marshal(merged.Data())
+}
+
+// DetectFormat returns the format by file extension, if the extension is unknown
+// the content is checked.
+func DetectFormat(path string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	content := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(content) > 0 && (content[0] == '{' || content[0] == '[') && json.Valid(content) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+func Unmarshal(format Format, data []byte, out any) error {
+	return decodeInto(out, func(v any) error {
+		switch format {
+		case FormatJSON:
+			return json.Unmarshal(data, v)
+		case FormatYAML:
+			return yaml.Unmarshal(data, v)
+		default:
+			return fmt.Errorf("unsupported config format %s", format)
+		}
+	})
+}
+
+// decodeInto calls decode with out, or with a map if out is a *collection.MapWrapper.
+func decodeInto(out any, decode func(v any) error) error {
+	mw, ok := out.(*collection.MapWrapper)
+	if !ok {
+		return decode(out)
+	}
+
+	var obj map[string]any
+	if err := decode(&obj); err != nil {
+		return err
+	}
+	if obj == nil {
+		obj = make(map[string]any)
+	}
+	*mw = *collection.NewMapWrapper(obj)
+	return nil
+}
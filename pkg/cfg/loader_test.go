@@ -0,0 +1,129 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/collection"
+)
+
+type testCfg struct {
+	Name string `yaml:"name" json:"name"`
+	Port int    `yaml:"port" json:"port"`
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoader_LoadFirstFound(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing", "config.yaml")
+	first := filepath.Join(dir, "first", "config.yaml")
+	second := filepath.Join(dir, "second", "config.yaml")
+	writeTestFile(t, first, "name: first\nport: 8080\n")
+	writeTestFile(t, second, "name: second\nport: 9090\n")
+
+	var cfg testCfg
+	path, err := NewLoader(missing, first, second).Load(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != first {
+		t.Errorf("expected %s, got %s", first, path)
+	}
+	if cfg.Name != "first" || cfg.Port != 8080 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoader_LoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	byExt := filepath.Join(dir, "config.json")
+	byContent := filepath.Join(dir, "config.conf")
+	writeTestFile(t, byExt, `{"name": "json", "port": 1}`)
+	writeTestFile(t, byContent, `{"name": "sniffed", "port": 2}`)
+
+	var cfg testCfg
+	if _, err := NewLoader(byExt).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "json" || cfg.Port != 1 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if format := DetectFormat(byContent, []byte(`{"name": "sniffed"}`)); format != FormatJSON {
+		t.Errorf("expected json, got %s", format)
+	}
+	if _, err := NewLoader(byContent).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "sniffed" || cfg.Port != 2 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoader_LoadMapWrapper(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: wrapped\nsuites:\n  - a\n  - b\n")
+
+	var m collection.MapWrapper
+	if _, err := NewLoader(path).Load(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites []string
+	if err := m.Get("suites", &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites) != 2 || suites[1] != "b" {
+		t.Errorf("unexpected suites %v", suites)
+	}
+}
+
+func TestLoader_NotFound(t *testing.T) {
+	dir := t.TempDir()
+
+	var cfg testCfg
+	_, err := NewLoader(filepath.Join(dir, "a.yaml"), dir).Load(&cfg)
+	if !errors.Is(err, ErrCfgNotFound) {
+		t.Fatalf("expected ErrCfgNotFound, got %v", err)
+	}
+}
+
+func TestLoader_InvalidContent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeTestFile(t, path, `{"name": `)
+
+	var cfg testCfg
+	if _, err := NewLoader(path).Load(&cfg); err == nil {
+		t.Fatal("expect to fail")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestLoadCfg(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTestFile(t, filepath.Join(dir, "loadcfg-test.yaml"), "name: local\n")
+
+	var cfg testCfg
+	path, err := LoadCfg("golib-loadcfg-test", "loadcfg-test.yaml", &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "loadcfg-test.yaml") || cfg.Name != "local" {
+		t.Errorf("unexpected result %s %+v", path, cfg)
+	}
+}
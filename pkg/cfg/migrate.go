@@ -0,0 +1,226 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// VersionKey is the top-level key holding the version of a config, a config
+// without it is version 0.
+const VersionKey = "version"
+
+// Migration upgrades a config tree in place from one version to the next.
+type Migration func(data map[string]any) error
+
+// Migrations is a registry of the steps which upgrade a config to Latest.
+type Migrations struct {
+	steps  map[int]Migration
+	latest int
+}
+
+func NewMigrations() *Migrations {
+	return &Migrations{steps: make(map[int]Migration)}
+}
+
+// Register registers the migration from version to version+1.
+func (m *Migrations) Register(from int, migration Migration) error {
+	if from < 0 {
+		return fmt.Errorf("invalid config version %d", from)
+	}
+	if _, ok := m.steps[from]; ok {
+		return fmt.Errorf("migration from version %d is already registered", from)
+	}
+	m.steps[from] = migration
+	m.latest = max(m.latest, from+1)
+	return nil
+}
+
+// Latest returns the version configs are migrated to.
+func (m *Migrations) Latest() int {
+	return m.latest
+}
+
+// Migrate upgrades data to Latest and sets its version, it returns the version
+// data had before.
+func (m *Migrations) Migrate(data map[string]any) (int, error) {
+	from, err := ConfigVersion(data)
+	if err != nil {
+		return 0, err
+	}
+	if from > m.latest {
+		return from, fmt.Errorf("config version %d is newer than the supported version %d", from, m.latest)
+	}
+
+	for version := from; version < m.latest; version++ {
+		step, ok := m.steps[version]
+		if !ok {
+			return from, fmt.Errorf("no migration from version %d", version)
+		}
+		if err = step(data); err != nil {
+			return from, fmt.Errorf("failed to migrate from version %d: %w", version, err)
+		}
+		data[VersionKey] = version + 1
+	}
+	return from, nil
+}
+
+// ConfigVersion returns the VersionKey of data, 0 if it isn't set.
+func ConfigVersion(data map[string]any) (int, error) {
+	switch version := data[VersionKey].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		if version >= 0 {
+			return version, nil
+		}
+	case float64:
+		if version >= 0 && version == math.Trunc(version) && version <= math.MaxInt32 {
+			return int(version), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid config version %v", data[VersionKey])
+}
+
+// migrate upgrades the config file with Migrations and writes it back if
+// WriteBack is set, unless TrustedKey is set since the signature would no
+// longer match. Includes, overlays and secrets are not resolved before.
+func (l *Loader) migrate(path string, format Format, data []byte) ([]byte, error) {
+	var (
+		obj      map[string]any
+		from     int
+		migrated []byte
+		err      error
+	)
+
+	switch format {
+	case FormatYAML:
+		var editor *Editor
+		if editor, err = newEditor(path, data); err != nil {
+			return nil, err
+		}
+		root := editor.doc.Content[0]
+		if err = root.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+		if obj == nil {
+			obj = make(map[string]any)
+		}
+		if from, err = l.Migrations.Migrate(obj); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		if from == l.Migrations.Latest() {
+			return data, nil
+		}
+		if editor.doc.Content[0], err = syncNode(root, obj); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		if migrated, err = editor.Bytes(); err != nil {
+			return nil, err
+		}
+	default:
+		if err = Unmarshal(format, data, &obj); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+		if obj == nil {
+			obj = make(map[string]any)
+		}
+		if from, err = l.Migrations.Migrate(obj); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		if from == l.Migrations.Latest() {
+			return data, nil
+		}
+		if migrated, err = json.MarshalIndent(obj, "", "  "); err != nil {
+			return nil, err
+		}
+		migrated = append(migrated, '\n')
+	}
+
+	if l.WriteBack && l.TrustedKey != nil {
+		// a rewritten file wouldn't match its signature on the next load
+		log.Warnf("not writing back signed config file %s, upgrade and sign it again", path)
+	} else if l.WriteBack {
+		if err = writeFileAtomic(path, migrated); err != nil {
+			return nil, err
+		}
+		log.Infof("upgraded config file %s from version %d to %d", path, from, l.Migrations.Latest())
+	}
+	return migrated, nil
+}
+
+// syncNode returns node updated to value. Unchanged nodes are kept with their
+// tags, comments and style, new mapping keys are appended in sorted order.
+func syncNode(node *yaml.Node, value any) (*yaml.Node, error) {
+	var current any
+	if err := node.Decode(&current); err == nil && reflect.DeepEqual(current, value) {
+		return node, nil
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		if node.Kind != yaml.MappingNode {
+			break
+		}
+		content := make([]*yaml.Node, 0, 2*len(value))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			child, ok := value[node.Content[i].Value]
+			if !ok {
+				continue
+			}
+			synced, err := syncNode(node.Content[i+1], child)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, node.Content[i], synced)
+		}
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			if mappingIndex(node, key) >= 0 {
+				continue
+			}
+			child := &yaml.Node{}
+			if err := child.Encode(value[key]); err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		}
+		node.Content = content
+		return node, nil
+	case []any:
+		if node.Kind != yaml.SequenceNode {
+			break
+		}
+		content := make([]*yaml.Node, len(value))
+		for i, item := range value {
+			if i < len(node.Content) {
+				synced, err := syncNode(node.Content[i], item)
+				if err != nil {
+					return nil, err
+				}
+				content[i] = synced
+				continue
+			}
+			content[i] = &yaml.Node{}
+			if err := content[i].Encode(item); err != nil {
+				return nil, err
+			}
+		}
+		node.Content = content
+		return node, nil
+	}
+
+	newNode := &yaml.Node{}
+	if err := newNode.Encode(value); err != nil {
+		return nil, err
+	}
+	newNode.HeadComment = node.HeadComment
+	newNode.LineComment = node.LineComment
+	newNode.FootComment = node.FootComment
+	return newNode, nil
+}
@@ -0,0 +1,152 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMigrations(t *testing.T) *Migrations {
+	m := NewMigrations()
+	steps := map[int]Migration{
+		// 1 moves host into server
+		1: func(data map[string]any) error {
+			data["server"] = map[string]any{"host": data["host"]}
+			delete(data, "host")
+			return nil
+		},
+		// 2 adds the timeout
+		2: func(data map[string]any) error {
+			data["server"].(map[string]any)["timeout"] = 30
+			return nil
+		},
+	}
+	for from, step := range steps {
+		if err := m.Register(from, step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+type migratedCfg struct {
+	Name   string `yaml:"name" json:"name"`
+	Server struct {
+		Host    string `yaml:"host" json:"host"`
+		Timeout int    `yaml:"timeout" json:"timeout"`
+	} `yaml:"server" json:"server"`
+}
+
+func TestMigrations_Register(t *testing.T) {
+	m := newTestMigrations(t)
+	if m.Latest() != 3 {
+		t.Errorf("expected latest version 3, got %d", m.Latest())
+	}
+	if err := m.Register(1, func(map[string]any) error { return nil }); err == nil {
+		t.Error("expected error for duplicate migration")
+	}
+
+	for _, data := range []map[string]any{
+		{"version": 4},
+		{"version": 0},
+		{"version": "one"},
+		{"version": 1.5},
+	} {
+		if _, err := m.Migrate(data); err == nil {
+			t.Errorf("expected error for %v", data)
+		}
+	}
+}
+
+func TestLoader_MigrateWriteBack(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "# app config\nversion: 1\nname: app # the name\nhost: example.com\n\nmode: \"0644\"\n")
+
+	loader := NewLoader(path)
+	loader.Migrations = newTestMigrations(t)
+
+	var cfg migratedCfg
+	if _, err := loader.Load(&cfg); err != nil || cfg.Server.Timeout != 30 {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# app config\nversion: 1\n") {
+		t.Errorf("expected file not to be written back, got\n%s", data)
+	}
+
+	loader.WriteBack = true
+	if _, err = loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "app" || cfg.Server.Host != "example.com" || cfg.Server.Timeout != 30 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	expected := "# app config\nversion: 3\nname: app # the name\n\nmode: \"0644\"\nserver:\n  host: example.com\n  timeout: 30\n"
+	if data, err = os.ReadFile(path); err != nil || string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+	if backup, _ := os.ReadFile(path + BackupSuffix); !strings.Contains(string(backup), "version: 1") {
+		t.Errorf("unexpected backup\n%s", backup)
+	}
+
+	// an up to date file is left alone
+	if err = os.Remove(path + BackupSuffix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no backup, got %v", err)
+	}
+}
+
+func TestLoader_MigrateQuotedBlankLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "version: 1\nname: \"hello\n\n  world\"\n\nhost: example.com\n")
+
+	loader := NewLoader(path)
+	loader.Migrations = newTestMigrations(t)
+
+	for _, writeBack := range []bool{false, true, false} {
+		loader.WriteBack = writeBack
+		var cfg migratedCfg
+		if _, err := loader.Load(&cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Name != "hello\nworld" || cfg.Server.Host != "example.com" {
+			t.Errorf("write back %v: unexpected config %+v", writeBack, cfg)
+		}
+	}
+
+	expected := "version: 3\nname: \"hello\\nworld\"\nserver:\n  host: example.com\n  timeout: 30\n"
+	if data, err := os.ReadFile(path); err != nil || string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestLoader_MigrateJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeTestFile(t, path, `{"version": 2, "name": "app", "server": {"host": "example.com"}}`)
+
+	loader := NewLoader(path)
+	loader.Migrations = newTestMigrations(t)
+
+	var cfg migratedCfg
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Host != "example.com" || cfg.Server.Timeout != 30 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"version": 2`) {
+		t.Errorf("expected file not to be written back, got %s", data)
+	}
+}
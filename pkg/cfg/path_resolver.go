@@ -0,0 +1,224 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+var ErrCfgConflict = errors.New("conflicting config files")
+
+// PathResolver resolves config paths following the XDG base directory
+// specification, all variables are looked up through Env. If WalkParents is
+// set, the parents of the current dir are searched as well, up to the root or
+// the first dir containing one of StopMarkers, e.g. .git.
+type PathResolver struct {
+	Env         env.Env
+	WalkParents bool
+	StopMarkers []string
+}
+
+func NewPathResolver(envs env.Env) *PathResolver {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+	return &PathResolver{Env: envs}
+}
+
+// ResolveCfgPath is like GetCfgPath but honors XDG_CONFIG_HOME, XDG_CONFIG_DIRS
+// and the <APP>_CONFIG override, and returns an error instead of exiting. Use a
+// PathResolver to search the parents of the current dir, or Loader.Resolver to
+// load from them.
+func ResolveCfgPath(appName string, cfgFile string, envs env.Env) ([]string, error) {
+	return NewPathResolver(envs).Resolve(appName, cfgFile)
+}
+
+// DiscoverCfgPath is like ResolveCfgPath but looks for any of names in each
+// config dir, see PathResolver.Discover.
+func DiscoverCfgPath(appName string, names []string, envs env.Env) ([]string, error) {
+	return NewPathResolver(envs).Discover(appName, names)
+}
+
+// CfgNames returns the default config names of the app in precedence order:
+// <app>.yaml, <app>.yml, <app>.json and .<app>rc.
+func CfgNames(appName string) []string {
+	return []string{appName + ".yaml", appName + ".yml", appName + ".json", "." + appName + "rc"}
+}
+
+// OverrideEnvName returns the variable which overrides the config path of the app,
+// e.g. MY_APP_CONFIG for my-app.
+func OverrideEnvName(appName string) string {
+	return envPrefix(appName) + "_CONFIG"
+}
+
+func envPrefix(appName string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(appName))
+}
+
+// Resolve returns the config paths in precedence order. If the override variable
+// is set, its value is the only path returned.
+func (r *PathResolver) Resolve(appName string, cfgFile string) ([]string, error) {
+	if override := r.Env.Get(OverrideEnvName(appName)); override != "" {
+		return []string{override}, nil
+	}
+
+	dirs, err := r.Dirs(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgPaths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		cfgPaths[i] = filepath.Join(dir, cfgFile)
+	}
+	return cfgPaths, nil
+}
+
+// Discover returns the existing config files of the app in precedence order, at
+// most one per config dir. The dirs are searched for any of names, two of them
+// in the same dir is an ErrCfgConflict as it's ambiguous which one is meant. If
+// the override variable is set, its value is the only path returned.
+func (r *PathResolver) Discover(appName string, names []string) ([]string, error) {
+	if override := r.Env.Get(OverrideEnvName(appName)); override != "" {
+		return []string{override}, nil
+	}
+
+	dirs, err := r.Dirs(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgPaths []string
+	for _, dir := range dirs {
+		var found string
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("failed to stat config file %s: %w", path, err)
+			}
+			if info.IsDir() {
+				continue
+			}
+			if found != "" {
+				return nil, fmt.Errorf("%w %s and %s", ErrCfgConflict, found, path)
+			}
+			found = path
+		}
+		if found != "" {
+			cfgPaths = append(cfgPaths, found)
+		}
+	}
+	return cfgPaths, nil
+}
+
+// Dirs returns the config dirs of the app in precedence order: the current dir
+// and its parents if WalkParents is set, $XDG_CONFIG_HOME/<app>,
+// $XDG_CONFIG_DIRS/<app> and /etc/<app>.
+func (r *PathResolver) Dirs(appName string) ([]string, error) {
+	curDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current dir: %w", err)
+	}
+
+	userDir, err := r.UserDir(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{curDir}
+	if r.WalkParents {
+		if dirs, err = r.parentDirs(curDir); err != nil {
+			return nil, err
+		}
+	}
+	dirs = append(dirs, userDir)
+	for _, dir := range r.configDirs() {
+		dirs = append(dirs, filepath.Join(dir, appName))
+	}
+	dirs = append(dirs, filepath.Join("/etc", appName))
+
+	var uniqueDirs []string
+	for _, dir := range dirs {
+		if !slices.Contains(uniqueDirs, dir) {
+			uniqueDirs = append(uniqueDirs, dir)
+		}
+	}
+	return uniqueDirs, nil
+}
+
+// parentDirs returns dir and its parents up to the root or the first dir
+// containing one of StopMarkers.
+func (r *PathResolver) parentDirs(dir string) ([]string, error) {
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+		for _, marker := range r.StopMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dirs, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs, nil
+		}
+		dir = parent
+	}
+}
+
+// UserDir returns $XDG_CONFIG_HOME/<app>, the dir of the user's config.
+func (r *PathResolver) UserDir(appName string) (string, error) {
+	configHome, err := r.configHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configHome, appName), nil
+}
+
+func (r *PathResolver) configHome() (string, error) {
+	if configHome := r.Env.Get("XDG_CONFIG_HOME"); filepath.IsAbs(configHome) {
+		return configHome, nil
+	}
+
+	homeDir := r.Env.Get("HOME")
+	if homeDir == "" {
+		var err error
+		if homeDir, err = os.UserHomeDir(); err != nil {
+			return "", fmt.Errorf("failed to get user home dir: %w", err)
+		}
+	}
+	return filepath.Join(homeDir, ".config"), nil
+}
+
+func (r *PathResolver) configDirs() []string {
+	configDirs := r.Env.Get("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+
+	var dirs []string
+	for _, dir := range filepath.SplitList(configDirs) {
+		// relative paths are invalid and must be ignored
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
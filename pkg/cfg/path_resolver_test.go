@@ -0,0 +1,293 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestOverrideEnvName(t *testing.T) {
+	testCases := map[string]string{
+		"app":       "APP_CONFIG",
+		"my-app":    "MY_APP_CONFIG",
+		"my.app2":   "MY_APP2_CONFIG",
+		"MixedCase": "MIXEDCASE_CONFIG",
+	}
+
+	for appName, expected := range testCases {
+		if got := OverrideEnvName(appName); got != expected {
+			t.Errorf("OverrideEnvName(%s) = %s, want %s", appName, got, expected)
+		}
+	}
+}
+
+func TestPathResolver_Resolve(t *testing.T) {
+	curDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"HOME":            "/home/tester",
+		"XDG_CONFIG_HOME": "/xdg/home",
+		"XDG_CONFIG_DIRS": "/xdg/dir1:relative/dir:/xdg/dir2",
+	})
+
+	cfgPaths, err := ResolveCfgPath("myapp", "config.yaml", envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(curDir, "config.yaml"),
+		"/xdg/home/myapp/config.yaml",
+		"/xdg/dir1/myapp/config.yaml",
+		"/xdg/dir2/myapp/config.yaml",
+		"/etc/myapp/config.yaml",
+	}
+	if !reflect.DeepEqual(cfgPaths, expected) {
+		t.Errorf("expected %v, got %v", expected, cfgPaths)
+	}
+}
+
+func TestPathResolver_Defaults(t *testing.T) {
+	curDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"HOME":            "/home/tester",
+		"XDG_CONFIG_HOME": "relative/is/ignored",
+	})
+
+	cfgPaths, err := NewPathResolver(envs).Resolve("myapp", "config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(curDir, "config.yaml"),
+		"/home/tester/.config/myapp/config.yaml",
+		"/etc/xdg/myapp/config.yaml",
+		"/etc/myapp/config.yaml",
+	}
+	if !reflect.DeepEqual(cfgPaths, expected) {
+		t.Errorf("expected %v, got %v", expected, cfgPaths)
+	}
+}
+
+func TestPathResolver_Override(t *testing.T) {
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"MYAPP_CONFIG": "/opt/myapp/custom.yaml",
+	})
+
+	cfgPaths, err := ResolveCfgPath("myapp", "config.yaml", envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfgPaths, []string{"/opt/myapp/custom.yaml"}) {
+		t.Errorf("unexpected paths %v", cfgPaths)
+	}
+}
+
+func TestPathResolver_NilEnv(t *testing.T) {
+	r := NewPathResolver(nil)
+	if r.Env != env.OSEnv {
+		t.Error("expected OSEnv when nil env is passed")
+	}
+}
+
+func TestPathResolver_Discover(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "work")
+	curPath := filepath.Join(curDir, "myapp.json")
+	userPath := filepath.Join(dir, "home", "myapp", ".myapprc")
+	xdgPath := filepath.Join(dir, "xdg", "myapp", "myapp.yml")
+	writeTestFile(t, curPath, `{"name": "cur"}`)
+	writeTestFile(t, userPath, "name: user\n")
+	writeTestFile(t, xdgPath, "name: xdg\n")
+	if err := os.MkdirAll(filepath.Join(curDir, "myapp.yaml"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS": filepath.Join(dir, "xdg"),
+	})
+
+	cfgPaths, err := DiscoverCfgPath("myapp", CfgNames("myapp"), envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{curPath, userPath, xdgPath}
+	if !reflect.DeepEqual(cfgPaths, expected) {
+		t.Errorf("expected %v, got %v", expected, cfgPaths)
+	}
+
+	var cfg testCfg
+	if path, err := NewLoader(cfgPaths...).Load(&cfg); err != nil || path != curPath || cfg.Name != "cur" {
+		t.Errorf("unexpected load of %s: %+v, %v", path, cfg, err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "home", "myapp", "myapp.yaml"), "name: conflict\n")
+	if _, err = DiscoverCfgPath("myapp", CfgNames("myapp"), envs); !errors.Is(err, ErrCfgConflict) {
+		t.Errorf("expected ErrCfgConflict, got %v", err)
+	}
+
+	envs = env.NewReadEnv(envs, map[string]string{"MYAPP_CONFIG": "/opt/myapp/custom.yaml"})
+	if cfgPaths, err = DiscoverCfgPath("myapp", CfgNames("myapp"), envs); err != nil || !reflect.DeepEqual(cfgPaths, []string{"/opt/myapp/custom.yaml"}) {
+		t.Errorf("unexpected paths %v, %v", cfgPaths, err)
+	}
+}
+
+func TestPathResolver_WalkParents(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "project", "sub", "work")
+	if err := os.MkdirAll(filepath.Join(dir, "project", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(curDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rootPath := filepath.Join(dir, "project", "myapp.yaml")
+	subPath := filepath.Join(dir, "project", "sub", "myapp.yaml")
+	writeTestFile(t, rootPath, "name: root\nport: 80\n")
+	writeTestFile(t, subPath, "port: 8080\n")
+	writeTestFile(t, filepath.Join(dir, "myapp.yaml"), "name: outside\n")
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"XDG_CONFIG_HOME": "/xdg/home",
+		"XDG_CONFIG_DIRS": "/xdg/dir",
+	})
+	r := NewPathResolver(envs)
+	r.WalkParents = true
+	r.StopMarkers = []string{".git"}
+
+	cfgPaths, err := r.Resolve("myapp", "myapp.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(curDir, "myapp.yaml"),
+		subPath,
+		rootPath,
+		"/xdg/home/myapp/myapp.yaml",
+		"/xdg/dir/myapp/myapp.yaml",
+		"/etc/myapp/myapp.yaml",
+	}
+	if !reflect.DeepEqual(cfgPaths, expected) {
+		t.Errorf("expected %v, got %v", expected, cfgPaths)
+	}
+
+	if cfgPaths, err = r.Discover("myapp", CfgNames("myapp")); err != nil || !reflect.DeepEqual(cfgPaths, []string{subPath, rootPath}) {
+		t.Errorf("unexpected discovered chain %v, %v", cfgPaths, err)
+	}
+
+	r.StopMarkers = nil
+	if cfgPaths, err = r.Resolve("myapp", "myapp.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(cfgPaths, filepath.Join(dir, "myapp.yaml")) || !slices.Contains(cfgPaths, "/myapp.yaml") {
+		t.Errorf("expected the chain up to the root, got %v", cfgPaths)
+	}
+}
+
+func TestLoader_ResolverWalkParents(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "project", "sub")
+	if err := os.MkdirAll(filepath.Join(dir, "project", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(curDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rootPath := filepath.Join(dir, "project", "config.yaml")
+	writeTestFile(t, rootPath, "name: project\nport: 80\n")
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS": filepath.Join(dir, "xdg"),
+	})
+	loader := NewLoader()
+	loader.Resolver = &PathResolver{Env: envs, WalkParents: true, StopMarkers: []string{".git"}}
+
+	var cfg testCfg
+	if path, err := loader.LoadApp("walkapp", "config.yaml", &cfg); err != nil || path != rootPath || cfg.Name != "project" {
+		t.Errorf("unexpected config %+v from %s, %v", cfg, path, err)
+	}
+
+	l, err := loader.LoadLayered("walkapp", "config.yaml", envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source, _ := l.Origin("port"); source != rootPath {
+		t.Errorf("expected port from %s, got %s", rootPath, source)
+	}
+
+	loader.Resolver = nil
+	if l, err = loader.LoadLayered("walkapp", "config.yaml", envs, nil); err != nil || len(l.Keys()) != 0 {
+		t.Errorf("expected no config without WalkParents, got %v, %v", l, err)
+	}
+}
+
+func TestLoader_Names(t *testing.T) {
+	dir := t.TempDir()
+	curDir := filepath.Join(dir, "work")
+	curPath := filepath.Join(curDir, "myapp.json")
+	userPath := filepath.Join(dir, "home", "myapp", ".myapprc")
+	writeTestFile(t, curPath, `{"name": "cur"}`)
+	writeTestFile(t, userPath, "name: user\nport: 80\n")
+	t.Chdir(curDir)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS": filepath.Join(dir, "xdg"),
+	})
+	loader := NewLoader()
+	loader.Resolver = NewPathResolver(envs)
+	loader.Names = CfgNames("myapp")
+
+	var cfg testCfg
+	if path, err := loader.LoadApp("myapp", "", &cfg); err != nil || path != curPath || cfg.Name != "cur" {
+		t.Errorf("unexpected config %+v from %s, %v", cfg, path, err)
+	}
+
+	l, err := loader.LoadLayered("myapp", "", envs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"name": curPath, "port": userPath}
+	if !reflect.DeepEqual(l.Origins(), expected) {
+		t.Errorf("expected %v, got %v", expected, l.Origins())
+	}
+
+	explanation, err := loader.ExplainKey("myapp", "", envs, "port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Source != userPath || len(explanation.Candidates) != 3 || explanation.Candidates[1].Source != curPath {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "home", "myapp", "myapp.yaml"), "name: conflict\n")
+	if _, err = loader.LoadApp("myapp", "", &cfg); !errors.Is(err, ErrCfgConflict) {
+		t.Errorf("expected ErrCfgConflict, got %v", err)
+	}
+	if _, err = loader.LoadLayered("myapp", "", envs, nil); !errors.Is(err, ErrCfgConflict) {
+		t.Errorf("expected ErrCfgConflict, got %v", err)
+	}
+
+	loader.Names = []string{"other.yaml"}
+	if _, err = loader.LoadApp("myapp", "", &cfg); !errors.Is(err, ErrCfgNotFound) {
+		t.Errorf("expected ErrCfgNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PermPolicy is what a Loader does when a config file fails CheckPerm.
+type PermPolicy int
+
+const (
+	PermIgnore PermPolicy = iota
+	PermWarn
+	PermRefuse
+)
+
+var ErrInsecurePerm = errors.New("insecure config file")
+
+func (p PermPolicy) String() string {
+	switch p {
+	case PermIgnore:
+		return "ignore"
+	case PermWarn:
+		return "warn"
+	case PermRefuse:
+		return "refuse"
+	default:
+		return fmt.Sprintf("PermPolicy(%d)", int(p))
+	}
+}
@@ -0,0 +1,74 @@
+//go:build unix
+
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPerm(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: app\n")
+
+	for perm, insecure := range map[os.FileMode]bool{0o600: false, 0o400: false, 0o640: true, 0o604: true, 0o620: true} {
+		if err := os.Chmod(path, perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckPerm(path); errors.Is(err, ErrInsecurePerm) != insecure {
+			t.Errorf("%#o: unexpected result %v", perm, err)
+		}
+	}
+
+	if os.Getuid() != 0 {
+		return
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(path, 12345, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPerm(path); !errors.Is(err, ErrInsecurePerm) {
+		t.Errorf("expected ErrInsecurePerm for foreign owner, got %v", err)
+	}
+}
+
+func TestLoader_Perm(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	includePath := filepath.Join(dir, "secrets.yaml")
+	writeTestFile(t, path, "name: app\nport: !include secrets.yaml\n")
+	writeTestFile(t, includePath, "8080\n")
+	for _, file := range []string{path, includePath} {
+		if err := os.Chmod(file, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loader := NewLoader(path)
+	loader.Perm = PermRefuse
+	var cfg testCfg
+	if _, err := loader.Load(&cfg); err != nil || cfg.Port != 8080 {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+
+	if err := os.Chmod(includePath, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.Load(&cfg); !errors.Is(err, ErrInsecurePerm) {
+		t.Errorf("expected ErrInsecurePerm for include, got %v", err)
+	}
+
+	loader.Perm = PermWarn
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Errorf("expected warning only, got %v", err)
+	}
+	loader.Perm = PermIgnore
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Errorf("expected no check, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// CheckPerm returns an ErrInsecurePerm error if the file is accessible by group
+// or others, or is owned by neither the current user nor root, like ssh checks
+// private keys.
+func CheckPerm(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("%w: %s has permissions %#o, it must not be accessible by group or others", ErrInsecurePerm, path, perm)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if uid := uint32(os.Getuid()); stat.Uid != uid && stat.Uid != 0 {
+			return fmt.Errorf("%w: %s is owned by uid %d, it must be owned by uid %d or root", ErrInsecurePerm, path, stat.Uid, uid)
+		}
+	}
+	return nil
+}
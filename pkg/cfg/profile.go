@@ -0,0 +1,68 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// ProfileEnvName returns the variable which selects the profiles of the app,
+// e.g. MY_APP_PROFILE for my-app.
+func ProfileEnvName(appName string) string {
+	return envPrefix(appName) + "_PROFILE"
+}
+
+// ActiveProfiles returns the comma separated profiles of the <APP>_PROFILE
+// variable in the order they are applied.
+func ActiveProfiles(appName string, envs env.Env) []string {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+
+	var profiles []string
+	for _, profile := range strings.Split(envs.Get(ProfileEnvName(appName)), ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// ProfilePath returns the overlay of the profile for the config file, e.g.
+// config.staging.yaml for config.yaml and staging.
+func ProfilePath(path string, profile string) string {
+	ext := filepath.Ext(path)
+	if ext == filepath.Base(path) {
+		// dot files like .apprc have no extension
+		ext = ""
+	}
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// profilePaths returns the existing overlays of Profiles for the config file.
+func (l *Loader) profilePaths(path string) ([]string, error) {
+	var paths []string
+	for _, profile := range l.Profiles {
+		if strings.ContainsAny(profile, `/\`) || profile == "." || profile == ".." {
+			return nil, fmt.Errorf("invalid profile %s", profile)
+		}
+
+		profilePath := ProfilePath(path, profile)
+		info, err := os.Stat(profilePath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, profilePath)
+		}
+	}
+	return paths, nil
+}
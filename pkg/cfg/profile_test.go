@@ -0,0 +1,88 @@
+package cfg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestActiveProfiles(t *testing.T) {
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"MY_APP_PROFILE": " staging, ,local ",
+	})
+
+	if profiles := ActiveProfiles("my-app", envs); !reflect.DeepEqual(profiles, []string{"staging", "local"}) {
+		t.Errorf("unexpected profiles %v", profiles)
+	}
+	if profiles := ActiveProfiles("other", envs); len(profiles) != 0 {
+		t.Errorf("expected no profiles, got %v", profiles)
+	}
+}
+
+func TestProfilePath(t *testing.T) {
+	testCases := map[string]string{
+		"/etc/app/config.yaml": "/etc/app/config.staging.yaml",
+		"config.json":          "config.staging.json",
+		".apprc":               ".apprc.staging",
+	}
+	for path, expected := range testCases {
+		if got := ProfilePath(path, "staging"); got != expected {
+			t.Errorf("ProfilePath(%s) = %s, want %s", path, got, expected)
+		}
+	}
+}
+
+func TestLoader_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: base\nserver:\n  host: localhost\n  port: 80\n")
+	writeTestFile(t, filepath.Join(dir, "config.staging.yaml"), "name: staging\nserver:\n  port: 8080\n")
+	writeTestFile(t, filepath.Join(dir, "config.local.yaml"), "server:\n  host: 127.0.0.1\n")
+
+	loader := NewLoader(path)
+	loader.Profiles = []string{"staging", "missing", "local"}
+
+	var cfg struct {
+		Name   string
+		Server struct {
+			Host string
+			Port int
+		}
+	}
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "staging" || cfg.Server.Host != "127.0.0.1" || cfg.Server.Port != 8080 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	loader.Profiles = []string{"../config"}
+	if _, err := loader.Load(&cfg); err == nil {
+		t.Error("expected invalid profile to fail")
+	}
+}
+
+func TestLoader_ProfilesJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeTestFile(t, path, `{"name": "base", "server": {"host": "localhost", "port": 80}}`)
+	writeTestFile(t, filepath.Join(dir, "config.prod.json"), `{"server": {"port": 443}}`)
+
+	loader := NewLoader(path)
+	loader.Profiles = []string{"prod"}
+
+	var cfg testCfg
+	var obj map[string]any
+	if _, err := loader.Load(&obj); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	server := obj["server"].(map[string]any)
+	if cfg.Name != "base" || server["port"] != float64(443) || server["host"] != "localhost" {
+		t.Errorf("unexpected config %v", obj)
+	}
+}
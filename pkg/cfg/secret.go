@@ -0,0 +1,145 @@
+package cfg
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"github.com/zhaojunlucky/golib/pkg/security"
+	"gopkg.in/yaml.v3"
+)
+
+const SecretTag = "!secret"
+
+// SecretDecrypter decrypts the base64 decoded values of !secret tags.
+type SecretDecrypter interface {
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// ECIESSecrets decrypts secrets encrypted by EncryptSecret.
+type ECIESSecrets struct {
+	key    *ecdsa.PrivateKey
+	helper security.ECIESHelper
+}
+
+func NewECIESSecrets(key *ecdsa.PrivateKey) *ECIESSecrets {
+	return &ECIESSecrets{key: key}
+}
+
+// NewECIESSecretsFromFile reads the PEM encoded private key from path.
+func NewECIESSecretsFromFile(path string) (*ECIESSecrets, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	key, err := security.ReadECPrivateKey(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+	return NewECIESSecrets(key), nil
+}
+
+// NewECIESSecretsFromEnv reads the PEM encoded private key from the variable name.
+func NewECIESSecretsFromEnv(envs env.Env, name string) (*ECIESSecrets, error) {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+	if !envs.Contains(name) {
+		return nil, fmt.Errorf("private key variable %s is not set", name)
+	}
+
+	key, err := security.ReadECPrivateKey(strings.NewReader(envs.Get(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key from %s: %w", name, err)
+	}
+	return NewECIESSecrets(key), nil
+}
+
+func (s *ECIESSecrets) Decrypt(data []byte) ([]byte, error) {
+	return s.helper.DecryptWithPrivate(s.key, data)
+}
+
+// AESSecrets decrypts secrets encrypted by EncryptAESSecret.
+type AESSecrets struct {
+	helper *security.AESHelper
+}
+
+func NewAESSecrets(key []byte) *AESSecrets {
+	return &AESSecrets{helper: security.NewAESHelper(key)}
+}
+
+func (s *AESSecrets) Decrypt(data []byte) ([]byte, error) {
+	// the packed data starts with the nonce size, check it to not panic on invalid input
+	if len(data) < 4+security.NonceSize || binary.BigEndian.Uint32(data) != security.NonceSize {
+		return nil, fmt.Errorf("invalid AES-GCM secret")
+	}
+	return s.helper.DecryptGCM(data)
+}
+
+// EncryptSecret encrypts value for the public key, the result is the value of a
+// !secret tag.
+func EncryptSecret(key *ecdsa.PublicKey, value []byte) (string, error) {
+	helper := security.ECIESHelper{}
+	encrypted, err := helper.EncryptWithPublic(key, value)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// EncryptAESSecret encrypts value with AES-GCM, the result is the value of a
+// !secret tag.
+func EncryptAESSecret(key []byte, value []byte) (string, error) {
+	encrypted, err := security.NewAESHelper(key).EncryptGCM(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// decryptSecrets replaces the values of !secret tags with the decrypted text.
+func (d *Document) decryptSecrets(node *yaml.Node, path string, secrets SecretDecrypter) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := d.decryptSecrets(node.Content[i+1], joinKey(path, node.Content[i].Value), secrets); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if err := d.decryptSecrets(child, fmt.Sprintf("%s[%d]", path, i), secrets); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if node.Tag != SecretTag {
+		return nil
+	}
+	if secrets == nil {
+		return fmt.Errorf("%s %s: no decrypter for %s", d.Position(node), path, SecretTag)
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(node.Value), ""))
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid secret: %w", d.Position(node), path, err)
+	}
+	decrypted, err := secrets.Decrypt(encrypted)
+	if err != nil {
+		return fmt.Errorf("%s %s: failed to decrypt secret: %w", d.Position(node), path, err)
+	}
+
+	node.Value = string(decrypted)
+	node.Tag = "!!str"
+	node.Style = 0
+	return nil
+}
@@ -0,0 +1,135 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+type testSecretCfg struct {
+	DB struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+	} `yaml:"db"`
+	Tokens []string `yaml:"tokens"`
+}
+
+func TestLoader_ECIESSecrets(t *testing.T) {
+	key, err := security.GenerateECKeyPair("secp256r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := EncryptSecret(&key.PublicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := EncryptSecret(&key.PublicKey, []byte("t0ken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, "db:\n  user: admin\n  password: !secret "+password+"\ntokens:\n  - !secret "+token+"\n")
+
+	keyPEM := new(bytes.Buffer)
+	if err = security.WriteECPrivateKey(key, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	writeTestFile(t, keyPath, keyPEM.String())
+
+	fromFile, err := NewECIESSecretsFromFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromEnv, err := NewECIESSecretsFromEnv(env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{
+		"APP_KEY": keyPEM.String(),
+	}), "APP_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secrets := range []SecretDecrypter{fromFile, fromEnv} {
+		loader := NewLoader(path)
+		loader.Secrets = secrets
+
+		var cfg testSecretCfg
+		if _, err = loader.Load(&cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DB.User != "admin" || cfg.DB.Password != "s3cret" || cfg.Tokens[0] != "t0ken" {
+			t.Errorf("unexpected config %+v", cfg)
+		}
+	}
+}
+
+func TestLoader_AESSecrets(t *testing.T) {
+	key, _ := hex.DecodeString("24cd27f296351a934855f099c091dc777a8fac258f1fdb7531cd71d7d05f48e0")
+	password, err := EncryptAESSecret(key, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, "db:\n  password: !secret "+password+"\n")
+
+	loader := NewLoader(path)
+	loader.Secrets = NewAESSecrets(key)
+
+	var cfg testSecretCfg
+	if _, err = loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Password != "s3cret" {
+		t.Errorf("unexpected password %s", cfg.DB.Password)
+	}
+}
+
+func TestLoader_SecretErrors(t *testing.T) {
+	key, _ := hex.DecodeString("24cd27f296351a934855f099c091dc777a8fac258f1fdb7531cd71d7d05f48e0")
+	path := filepath.Join(t.TempDir(), "app.yaml")
+
+	testCases := []struct {
+		name     string
+		content  string
+		secrets  SecretDecrypter
+		expected string
+	}{
+		{
+			name:     "no decrypter",
+			content:  "db:\n  password: !secret AAAA\n",
+			expected: path + ":2:13 db.password: no decrypter",
+		},
+		{
+			name:     "invalid base64",
+			content:  "db:\n  password: !secret '%%%'\n",
+			secrets:  NewAESSecrets(key),
+			expected: path + ":2:13 db.password: invalid secret",
+		},
+		{
+			name:     "invalid data",
+			content:  "tokens:\n  - !secret AAAAAAAA\n",
+			secrets:  NewAESSecrets(key),
+			expected: path + ":2:5 tokens[0]: failed to decrypt secret",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeTestFile(t, path, tc.content)
+			loader := NewLoader(path)
+			loader.Secrets = tc.secrets
+
+			var cfg testSecretCfg
+			_, err := loader.Load(&cfg)
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Fatalf("expected %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cfg
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+// SignatureSuffix is appended to the path of a config file for its detached
+// signature.
+const SignatureSuffix = ".sig"
+
+var ErrInvalidSignature = errors.New("invalid config signature")
+
+// SignCfg writes the base64 encoded ECDSA signature of the SHA-256 hash of the
+// config file to path + SignatureSuffix.
+func SignCfg(path string, key *ecdsa.PrivateKey) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign config file %s: %w", path, err)
+	}
+	return os.WriteFile(path+SignatureSuffix, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0o644)
+}
+
+// SignCfgWithKeyFile is like SignCfg with the PEM encoded private key at keyPath.
+func SignCfgWithKeyFile(path string, keyPath string) error {
+	file, err := os.Open(keyPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	key, err := security.ReadECPrivateKey(file)
+	if err != nil {
+		return fmt.Errorf("failed to read private key %s: %w", keyPath, err)
+	}
+	return SignCfg(path, key)
+}
+
+// VerifyCfg checks the content of the config file at path against the signature
+// in path + SignatureSuffix, a missing or wrong signature is an
+// ErrInvalidSignature.
+func VerifyCfg(path string, data []byte, key *ecdsa.PublicKey) error {
+	encoded, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s has no signature", ErrInvalidSignature, path)
+		}
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidSignature, path+SignatureSuffix, err)
+	}
+	hash := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(key, hash[:], sig) {
+		return fmt.Errorf("%w: %s doesn't match its signature", ErrInvalidSignature, path)
+	}
+	return nil
+}
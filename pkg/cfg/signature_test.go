@@ -0,0 +1,103 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+func TestLoader_TrustedKey(t *testing.T) {
+	key, err := security.GenerateECKeyPair("prime256v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	keyFile, err := os.Create(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = security.WriteECPrivateKey(key, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	keyFile.Close()
+
+	path := filepath.Join(dir, "config.yaml")
+	includePath := filepath.Join(dir, "port.yaml")
+	writeTestFile(t, path, "name: edge\nport: !include port.yaml\n")
+	writeTestFile(t, includePath, "8080\n")
+
+	loader := NewLoader(path)
+	loader.TrustedKey = &key.PublicKey
+	var cfg testCfg
+	if _, err = loader.Load(&cfg); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for unsigned config, got %v", err)
+	}
+
+	if err = SignCfgWithKeyFile(path, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if err = SignCfg(includePath, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loader.Load(&cfg); err != nil || cfg.Name != "edge" || cfg.Port != 8080 {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+
+	writeTestFile(t, includePath, "9090\n")
+	if _, err = loader.Load(&cfg); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for modified include, got %v", err)
+	}
+
+	other, err := security.GenerateECKeyPair("prime256v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SignCfg(includePath, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loader.Load(&cfg); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for foreign key, got %v", err)
+	}
+
+	writeTestFile(t, includePath+SignatureSuffix, "not base64!\n")
+	if _, err = loader.Load(&cfg); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for garbage, got %v", err)
+	}
+}
+
+func TestLoader_TrustedKeyWriteBack(t *testing.T) {
+	key, err := security.GenerateECKeyPair("prime256v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "version: 1\nname: app\nhost: example.com\n"
+	writeTestFile(t, path, content)
+	if err = SignCfg(path, key); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader(path)
+	loader.TrustedKey = &key.PublicKey
+	loader.Migrations = newTestMigrations(t)
+	loader.WriteBack = true
+
+	// the migrated config is used but the signed file is left alone, so the
+	// second load still verifies
+	for i := 0; i < 2; i++ {
+		var cfg migratedCfg
+		if _, err = loader.Load(&cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Host != "example.com" || cfg.Server.Timeout != 30 {
+			t.Errorf("unexpected config %+v", cfg)
+		}
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != content {
+		t.Errorf("expected signed file to be unchanged, got\n%s", data)
+	}
+}
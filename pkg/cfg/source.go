@@ -0,0 +1,234 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+// Source supplies one layer of config. Load returns ErrCfgNotFound if the
+// source doesn't exist, ID is recorded as the origin of its values.
+type Source interface {
+	ID() string
+	Load() ([]byte, Format, error)
+}
+
+// MergeSource is a Source which is merged into the config of the previous
+// sources by LoadSources, e.g. to match their keys.
+type MergeSource interface {
+	Source
+	MergeInto(layered *Layered) error
+}
+
+// LoadSources deep-merges the sources in order, values of later sources win.
+// Sources which don't exist are skipped.
+func LoadSources(sources ...Source) (*Layered, error) {
+	layered := NewLayered()
+	for _, source := range sources {
+		if merger, ok := source.(MergeSource); ok {
+			if err := merger.MergeInto(layered); err != nil {
+				return nil, fmt.Errorf("failed to load config source %s: %w", source.ID(), err)
+			}
+			continue
+		}
+
+		data, format, err := source.Load()
+		if err != nil {
+			if errors.Is(err, ErrCfgNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to load config source %s: %w", source.ID(), err)
+		}
+
+		var obj map[string]any
+		if err = Unmarshal(format, data, &obj); err != nil {
+			return nil, fmt.Errorf("failed to decode config source %s: %w", source.ID(), err)
+		}
+		layered.Merge(source.ID(), obj)
+	}
+	return layered, nil
+}
+
+// FileSource reads a config file, the format is detected by DetectFormat.
+type FileSource struct {
+	Path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+func (s *FileSource) ID() string {
+	return s.Path
+}
+
+func (s *FileSource) Load() ([]byte, Format, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("%w: %s", ErrCfgNotFound, s.Path)
+		}
+		return nil, "", err
+	}
+	return data, DetectFormat(s.Path, data), nil
+}
+
+// EnvSource reads the <APP>_ variables like Layered.MergeEnv, LoadSources
+// matches their keys against the previous sources.
+type EnvSource struct {
+	AppName string
+	Env     env.Env
+}
+
+func NewEnvSource(appName string, envs env.Env) *EnvSource {
+	if envs == nil {
+		envs = env.OSEnv
+	}
+	return &EnvSource{AppName: appName, Env: envs}
+}
+
+func (s *EnvSource) ID() string {
+	return "env:" + envPrefix(s.AppName) + "_"
+}
+
+func (s *EnvSource) Load() ([]byte, Format, error) {
+	layered := NewLayered()
+	layered.MergeEnv(s.AppName, s.Env)
+	data, err := yaml.Marshal(layered.Data())
+	return data, FormatYAML, err
+}
+
+func (s *EnvSource) MergeInto(layered *Layered) error {
+	layered.mergeEnv(s.AppName, s.Env, s.ID())
+	return nil
+}
+
+// MemorySource supplies a config held in memory, e.g. defaults or test fixtures.
+type MemorySource struct {
+	Name string
+	Data map[string]any
+}
+
+func NewMemorySource(name string, data map[string]any) *MemorySource {
+	return &MemorySource{Name: name, Data: data}
+}
+
+func (s *MemorySource) ID() string {
+	return s.Name
+}
+
+func (s *MemorySource) Load() ([]byte, Format, error) {
+	data, err := yaml.Marshal(s.Data)
+	return data, FormatYAML, err
+}
+
+// HTTPSource fetches a config from URL. The ETag of the response is sent as
+// If-None-Match by later loads, a 304 response reuses the cached config. A 404
+// response is ErrCfgNotFound.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+	Header http.Header
+
+	mu     sync.Mutex
+	etag   string
+	data   []byte
+	format Format
+}
+
+func NewHTTPSource(rawURL string) *HTTPSource {
+	return &HTTPSource{URL: rawURL, Client: http.DefaultClient}
+}
+
+func (s *HTTPSource) ID() string {
+	return s.URL
+}
+
+func (s *HTTPSource) Load() ([]byte, Format, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.fetch(); err != nil {
+		return nil, "", err
+	}
+	return s.data, s.format, nil
+}
+
+// Refresh fetches the config, it returns true if the server sent a new one.
+func (s *HTTPSource) Refresh() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetch()
+}
+
+func (s *HTTPSource) fetch() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	if s.etag != "" && s.data != nil {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if s.data == nil {
+			return false, fmt.Errorf("unexpected %s without cached config", resp.Status)
+		}
+		return false, nil
+	case http.StatusNotFound:
+		return false, fmt.Errorf("%w: %s", ErrCfgNotFound, s.URL)
+	default:
+		return false, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	s.data = data
+	s.etag = resp.Header.Get("ETag")
+	s.format = s.detectFormat(resp.Header.Get("Content-Type"), data)
+	return true, nil
+}
+
+// detectFormat checks the content type first, then the URL path and content.
+func (s *HTTPSource) detectFormat(contentType string, data []byte) Format {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON
+	case strings.HasSuffix(mediaType, "yaml"):
+		return FormatYAML
+	}
+
+	path := s.URL
+	if u, err := url.Parse(s.URL); err == nil {
+		path = u.Path
+	}
+	return DetectFormat(path, data)
+}
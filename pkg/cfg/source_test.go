@@ -0,0 +1,139 @@
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestLoadSources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeTestFile(t, path, `{"server": {"host": "file.local", "port": 80}}`)
+
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"SRCAPP_SERVER__PORT": "9090"})
+
+	layered, err := LoadSources(
+		NewMemorySource("defaults", map[string]any{"name": "app", "server": map[string]any{"port": 8080}}),
+		NewFileSource(path),
+		NewFileSource(filepath.Join(dir, "missing.yaml")),
+		NewEnvSource("srcapp", envs),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"name":        "defaults",
+		"server.host": path,
+		"server.port": "env:SRCAPP_",
+	}
+	for key, source := range expected {
+		if origin, _ := layered.Origin(key); origin != source {
+			t.Errorf("expected %s from %s, got %s", key, source, origin)
+		}
+	}
+	if port, _ := layered.Get("server.port"); port != 9090 {
+		t.Errorf("expected port 9090, got %v", port)
+	}
+}
+
+func TestLoadSources_EnvCamelCase(t *testing.T) {
+	envs := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"MYAPP_LOGLEVEL": "debug"})
+	layered, err := LoadSources(
+		NewMemorySource("defaults", map[string]any{"logLevel": "info"}),
+		NewEnvSource("myapp", envs),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{"logLevel": "debug"}
+	if !reflect.DeepEqual(layered.Data(), expected) {
+		t.Errorf("expected %v, got %v", expected, layered.Data())
+	}
+	if origin, _ := layered.Origin("logLevel"); origin != "env:MYAPP_" {
+		t.Errorf("unexpected origin %s", origin)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	var requests, notModified atomic.Int32
+	content := atomic.Value{}
+	content.Store("name: v1\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body := content.Load().(string)
+		etag := `"` + body[6:8] + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	source := NewHTTPSource(server.URL + "/config")
+	var cfg testCfg
+	layered, err := LoadSources(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = layered.Decode(&cfg); err != nil || cfg.Name != "v1" {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+
+	if changed, err := source.Refresh(); err != nil || changed {
+		t.Errorf("expected no change, got %v, %v", changed, err)
+	}
+	if data, format, err := source.Load(); err != nil || string(data) != "name: v1\n" || format != FormatYAML {
+		t.Errorf("unexpected cached load %q, %s, %v", data, format, err)
+	}
+	if notModified.Load() != 2 {
+		t.Errorf("expected 2 conditional hits, got %d", notModified.Load())
+	}
+
+	content.Store("name: v2\n")
+	if changed, err := source.Refresh(); err != nil || !changed {
+		t.Errorf("expected change, got %v, %v", changed, err)
+	}
+	if data, _, _ := source.Load(); string(data) != "name: v2\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if requests.Load() != 5 {
+		t.Errorf("expected 5 requests, got %d", requests.Load())
+	}
+}
+
+func TestHTTPSource_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/config.json":
+			_, _ = w.Write([]byte(`{"name": "json"}`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	if _, _, err := NewHTTPSource(server.URL + "/missing").Load(); !errors.Is(err, ErrCfgNotFound) {
+		t.Errorf("expected ErrCfgNotFound, got %v", err)
+	}
+	if _, err := LoadSources(NewHTTPSource(server.URL + "/broken")); err == nil {
+		t.Error("expected error for 500 response")
+	}
+	if _, format, err := NewHTTPSource(server.URL + "/config.json").Load(); err != nil || format != FormatJSON {
+		t.Errorf("expected json format, got %s, %v", format, err)
+	}
+}
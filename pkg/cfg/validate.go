@@ -0,0 +1,375 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/collection"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultTag  = "default"
+	ValidateTag = "validate"
+)
+
+var typeErrLinePattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// ValidationError is a config value which violates its validate tag.
+type ValidationError struct {
+	File   string
+	Line   int
+	Column int
+	Path   string
+	Msg    string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d %s %s", e.File, e.Line, e.Column, e.Path, e.Msg)
+}
+
+type fieldRules struct {
+	required bool
+	min      *float64
+	max      *float64
+	oneOf    []string
+}
+
+// validate applies the default tags to the missing keys and checks the validate
+// tags of the struct out was decoded into. Supported rules are required, min=N,
+// max=N (the length for strings, slices and maps) and oneof=a b c.
+func (d *Document) validate(out any) error {
+	val := reflect.ValueOf(out)
+	for val.Kind() == reflect.Pointer && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []error
+	d.walkStruct(val, d.Root, d.Root, "", &errs)
+	return errors.Join(errs...)
+}
+
+// validateJSON applies the default tags and checks the validate tags of the
+// struct decoded from a JSON config like Document.Decode, fields are matched by
+// their json names. JSON which YAML can't parse, e.g. with \/ escapes, is
+// validated without positions.
+func validateJSON(path string, data []byte, out any) error {
+	if _, ok := out.(*collection.MapWrapper); ok {
+		return nil
+	}
+
+	doc := &Document{Path: path, files: make(map[*yaml.Node]string), json: true}
+	var docNode yaml.Node
+	if err := yaml.Unmarshal(data, &docNode); err == nil && len(docNode.Content) > 0 {
+		doc.Root = docNode.Content[0]
+	} else {
+		var value any
+		if err = json.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+		doc.Root = &yaml.Node{}
+		if err = doc.Root.Encode(value); err != nil {
+			return fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+	}
+	return doc.validate(out)
+}
+
+func (d *Document) walkStruct(val reflect.Value, node *yaml.Node, posNode *yaml.Node, path string, errs *[]error) {
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline, skip := yamlFieldName(field)
+		if d.json {
+			name, inline, skip = jsonFieldName(field)
+		}
+		if skip {
+			continue
+		}
+		fieldVal := val.Field(i)
+		if inline {
+			if fieldVal.Kind() == reflect.Struct {
+				d.walkStruct(fieldVal, node, posNode, path, errs)
+			}
+			continue
+		}
+
+		fieldPath := joinKey(path, name)
+		var keyNode, valueNode *yaml.Node
+		if node != nil && node.Kind == yaml.MappingNode {
+			j := mappingIndex(node, name)
+			if j < 0 && d.json {
+				j = foldMappingIndex(node, name)
+			}
+			if j >= 0 {
+				keyNode, valueNode = node.Content[j], node.Content[j+1]
+			}
+		}
+
+		fieldPos := posNode
+		if keyNode != nil {
+			fieldPos = keyNode
+		}
+
+		present := valueNode != nil
+		if def, ok := field.Tag.Lookup(DefaultTag); ok && !present {
+			if err := yaml.Unmarshal([]byte(def), fieldVal.Addr().Interface()); err != nil {
+				*errs = append(*errs, d.newValidationError(fieldPos, fieldPath, fmt.Sprintf("invalid default %q: %v", def, err)))
+				continue
+			}
+			present = true
+		}
+
+		if tag, ok := field.Tag.Lookup(ValidateTag); ok {
+			rules, err := parseRules(tag)
+			if err != nil {
+				*errs = append(*errs, d.newValidationError(fieldPos, fieldPath, err.Error()))
+			} else if msg := checkRules(fieldVal, present, rules); msg != "" {
+				*errs = append(*errs, d.newValidationError(fieldPos, fieldPath, msg))
+			}
+		}
+
+		d.walkValue(fieldVal, valueNode, fieldPos, fieldPath, errs)
+	}
+}
+
+// walkValue descends into structs, also within pointers, slices and maps.
+func (d *Document) walkValue(val reflect.Value, node *yaml.Node, posNode *yaml.Node, path string, errs *[]error) {
+	switch val.Kind() {
+	case reflect.Struct:
+		d.walkStruct(val, node, posNode, path, errs)
+	case reflect.Pointer:
+		if !val.IsNil() {
+			d.walkValue(val.Elem(), node, posNode, path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			var itemNode *yaml.Node
+			itemPos := posNode
+			if node != nil && node.Kind == yaml.SequenceNode && i < len(node.Content) {
+				itemNode, itemPos = node.Content[i], node.Content[i]
+			}
+			d.walkValue(val.Index(i), itemNode, itemPos, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, key := range val.MapKeys() {
+			item := reflect.New(val.Type().Elem()).Elem()
+			item.Set(val.MapIndex(key))
+			if item.Kind() != reflect.Struct && item.Kind() != reflect.Pointer {
+				continue
+			}
+
+			var itemNode *yaml.Node
+			itemPos := posNode
+			if node != nil && node.Kind == yaml.MappingNode {
+				if j := mappingIndex(node, key.String()); j >= 0 {
+					itemNode, itemPos = node.Content[j+1], node.Content[j]
+				}
+			}
+			d.walkValue(item, itemNode, itemPos, joinKey(path, key.String()), errs)
+			val.SetMapIndex(key, item)
+		}
+	}
+}
+
+func (d *Document) newValidationError(node *yaml.Node, path string, msg string) *ValidationError {
+	return &ValidationError{
+		File:   d.File(node),
+		Line:   node.Line,
+		Column: node.Column,
+		Path:   path,
+		Msg:    msg,
+	}
+}
+
+// positionTypeError prefixes the lines of a yaml.TypeError with the file name.
+func (d *Document) positionTypeError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return fmt.Errorf("failed to decode config file %s: %w", d.Path, err)
+	}
+
+	errs := make([]error, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		if match := typeErrLinePattern.FindStringSubmatch(msg); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			errs[i] = fmt.Errorf("%s:%d %s", d.lineFile(line), line, match[2])
+		} else {
+			errs[i] = fmt.Errorf("%s %s", d.Path, msg)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lineFile returns the file of the nodes at the line if it is unambiguous.
+func (d *Document) lineFile(line int) string {
+	var files []string
+	for node, file := range d.files {
+		if node.Line == line && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 1 {
+		return files[0]
+	}
+	return d.Path
+}
+
+func yamlFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	inline = slices.Contains(parts[1:], "inline")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, inline, false
+}
+
+// jsonFieldName returns the name of the field like encoding/json, embedded
+// structs without a name are inlined.
+func jsonFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name, _, _ = strings.Cut(tag, ",")
+	if name == "" {
+		if field.Anonymous {
+			return "", true, false
+		}
+		name = field.Name
+	}
+	return name, false, false
+}
+
+// foldMappingIndex is like mappingIndex but ignores case, as encoding/json does
+// if no key matches exactly.
+func foldMappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseRules parses a validate tag, unknown rules and invalid arguments are
+// errors so a typo doesn't turn the validation off.
+func parseRules(tag string) (fieldRules, error) {
+	var rules fieldRules
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			rules.required = true
+		case "min", "max":
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return rules, fmt.Errorf("invalid validate rule %s: %s is not a number", rule, arg)
+			}
+			if name == "min" {
+				rules.min = &v
+			} else {
+				rules.max = &v
+			}
+		case "oneof":
+			if rules.oneOf = strings.Fields(arg); len(rules.oneOf) == 0 {
+				return rules, fmt.Errorf("invalid validate rule %s: no values", rule)
+			}
+		default:
+			return rules, fmt.Errorf("unknown validate rule %s", rule)
+		}
+	}
+	return rules, nil
+}
+
+// checkRules returns the message of the first violated rule or an empty string,
+// the other rules are only checked if the value is present. Bools and numbers
+// are required to be present, other values also not to be empty.
+func checkRules(val reflect.Value, present bool, rules fieldRules) string {
+	if rules.required && (!present || (!isBoolOrNumber(val.Type()) && val.IsZero())) {
+		return "is required"
+	}
+	if !present {
+		return ""
+	}
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return ""
+		}
+		val = val.Elem()
+	}
+
+	subject := "must"
+	var num float64
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		num = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		num = val.Float()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		subject = "length must"
+		num = float64(val.Len())
+	default:
+		return ""
+	}
+
+	switch {
+	case rules.min != nil && rules.max != nil && (num < *rules.min || num > *rules.max):
+		return fmt.Sprintf("%s be between %s and %s", subject, formatNum(*rules.min), formatNum(*rules.max))
+	case rules.min != nil && num < *rules.min:
+		return fmt.Sprintf("%s be at least %s", subject, formatNum(*rules.min))
+	case rules.max != nil && num > *rules.max:
+		return fmt.Sprintf("%s be at most %s", subject, formatNum(*rules.max))
+	}
+
+	if len(rules.oneOf) > 0 && !slices.Contains(rules.oneOf, fmt.Sprint(val.Interface())) {
+		return fmt.Sprintf("must be one of %s", strings.Join(rules.oneOf, ", "))
+	}
+	return ""
+}
+
+func isBoolOrNumber(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func formatNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,194 @@
+package cfg
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServerCfg struct {
+	Host    string        `yaml:"host" default:"localhost"`
+	Port    int           `yaml:"port" default:"8080" validate:"min=1,max=65535"`
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+}
+
+type testAppCfg struct {
+	Name     string                   `yaml:"name" validate:"required"`
+	Level    string                   `yaml:"level" default:"info" validate:"oneof=debug info warn"`
+	Tags     []string                 `yaml:"tags" validate:"max=2"`
+	Server   testServerCfg            `yaml:"server"`
+	Backends []testServerCfg          `yaml:"backends"`
+	Named    map[string]testServerCfg `yaml:"named"`
+	Optional *testServerCfg           `yaml:"optional"`
+}
+
+func loadTestAppCfg(t *testing.T, content string) (testAppCfg, string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, content)
+
+	var cfg testAppCfg
+	_, err := NewLoader(path).Load(&cfg)
+	return cfg, path, err
+}
+
+func TestDocument_Defaults(t *testing.T) {
+	cfg, _, err := loadTestAppCfg(t, `
+name: app
+server:
+  port: 9090
+backends:
+  - host: b1
+named:
+  a:
+    host: a
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Level != "info" || cfg.Server.Host != "localhost" || cfg.Server.Port != 9090 || cfg.Server.Timeout != 5*time.Second {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.Backends[0].Host != "b1" || cfg.Backends[0].Port != 8080 {
+		t.Errorf("unexpected backends %+v", cfg.Backends)
+	}
+	if cfg.Named["a"].Port != 8080 || cfg.Optional != nil {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestDocument_ValidationErrors(t *testing.T) {
+	_, path, err := loadTestAppCfg(t, `level: trace
+tags: [a, b, c]
+server:
+  port: 70000
+backends:
+  - host: b1
+    port: 0
+`)
+	if err == nil {
+		t.Fatal("expect to fail")
+	}
+	t.Log(err)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %T", err)
+	}
+
+	expected := []string{
+		path + ":1:1 name is required",
+		path + ":1:1 level must be one of debug, info, warn",
+		path + ":2:1 tags length must be at most 2",
+		path + ":4:3 server.port must be between 1 and 65535",
+		path + ":7:5 backends[0].port must be between 1 and 65535",
+	}
+	for _, msg := range expected {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in %v", msg, err)
+		}
+	}
+}
+
+func TestDocument_RequiredZero(t *testing.T) {
+	type flagsCfg struct {
+		Enabled bool   `yaml:"enabled" validate:"required"`
+		Retries int    `yaml:"retries" validate:"required,max=3"`
+		Name    string `yaml:"name" validate:"required"`
+	}
+	path := filepath.Join(t.TempDir(), "app.yaml")
+
+	writeTestFile(t, path, "enabled: false\nretries: 0\nname: app\n")
+	if _, err := NewLoader(path).Load(&flagsCfg{}); err != nil {
+		t.Errorf("expected explicit zero values to be accepted, got %v", err)
+	}
+
+	writeTestFile(t, path, "name: \"\"\n")
+	_, err := NewLoader(path).Load(&flagsCfg{})
+	for _, msg := range []string{"enabled is required", "retries is required", "name is required"} {
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in %v", msg, err)
+		}
+	}
+}
+
+func TestDocument_InvalidRules(t *testing.T) {
+	type typoCfg struct {
+		Name string `yaml:"name" validate:"requird"`
+		Port int    `yaml:"port" validate:"min=abc"`
+		Mode string `yaml:"mode" validate:"oneof="`
+	}
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeTestFile(t, path, "name: app\nport: 1\nmode: a\n")
+
+	_, err := NewLoader(path).Load(&typoCfg{})
+	for _, msg := range []string{
+		path + ":1:1 name unknown validate rule requird",
+		path + ":2:1 port invalid validate rule min=abc: abc is not a number",
+		path + ":3:1 mode invalid validate rule oneof=: no values",
+	} {
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in %v", msg, err)
+		}
+	}
+}
+
+func TestDocument_IncludedPosition(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeTestFile(t, path, "name: app\nserver: !include server.yaml\n")
+	writeTestFile(t, filepath.Join(dir, "server.yaml"), "host: h\nport: -1\n")
+
+	var cfg testAppCfg
+	_, err := NewLoader(path).Load(&cfg)
+	expected := filepath.Join(dir, "server.yaml") + ":2:1 server.port must be between 1 and 65535"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestDocument_TypeError(t *testing.T) {
+	_, path, err := loadTestAppCfg(t, "name: app\nserver:\n  port: abc\n")
+	if err == nil || !strings.HasPrefix(err.Error(), path+":3 cannot unmarshal") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLoader_ValidateJSON(t *testing.T) {
+	type jsonServerCfg struct {
+		Host string `json:"host" default:"localhost"`
+		Port int    `default:"8080" validate:"max=10"`
+	}
+	type jsonAppCfg struct {
+		Name    string          `json:"name" validate:"required"`
+		Servers []jsonServerCfg `json:"servers"`
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.json")
+
+	writeTestFile(t, path, `{"name": "app", "servers": [{"port": 9}, {"host": "a\/b", "Port": 1}]}`)
+	var cfg jsonAppCfg
+	if _, err := NewLoader(path).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := []jsonServerCfg{{Host: "localhost", Port: 9}, {Host: "a/b", Port: 1}}
+	if !reflect.DeepEqual(cfg.Servers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, cfg.Servers)
+	}
+
+	writeTestFile(t, path, "{\n  \"servers\": [\n    {\"port\": 99},\n    {}\n  ]\n}\n")
+	_, err := NewLoader(path).Load(&jsonAppCfg{})
+	for _, msg := range []string{
+		path + ":1:1 name is required",
+		path + ":3:6 servers[0].Port must be at most 10",
+		path + ":4:5 servers[1].Port must be at most 10",
+	} {
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in %v", msg, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package cfg
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultWatchInterval = 5 * time.Second
+
+// Watcher polls the mtime and hash of config files, re-loads and validates the
+// config on change and atomically swaps the active config. If the new config
+// is invalid, the previous one is kept. Dirs are polled for added and removed
+// files.
+type Watcher[T any] struct {
+	Interval time.Duration
+	Validate func(cfg T) error
+
+	paths     []string
+	load      func() (T, []string, error)
+	current   atomic.Pointer[T]
+	stamps    map[string]fileStamp
+	callbacks []func(oldCfg, newCfg T)
+	mu        sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// NewWatcher watches paths and calls load to re-parse the config on change.
+func NewWatcher[T any](paths []string, load func() (T, error)) *Watcher[T] {
+	return newWatcher(paths, func() (T, []string, error) {
+		cfg, err := load()
+		return cfg, nil, err
+	})
+}
+
+// newWatcher is NewWatcher with a load which also returns the paths to watch
+// from then on, or nil to keep them.
+func newWatcher[T any](paths []string, load func() (T, []string, error)) *Watcher[T] {
+	return &Watcher[T]{
+		Interval: DefaultWatchInterval,
+		paths:    paths,
+		load:     load,
+		stamps:   make(map[string]fileStamp),
+	}
+}
+
+// NewFileWatcher watches paths and decodes the first existing one with Loader,
+// see NewLoaderWatcher.
+func NewFileWatcher[T any](paths ...string) *Watcher[T] {
+	return NewLoaderWatcher[T](NewLoader(paths...))
+}
+
+// NewLoaderWatcher decodes the config with loader and watches its Paths and the
+// files the last load read: includes, conf.d fragments, profile overlays and
+// with TrustedKey their signatures. The conf.d dir is polled for new fragments.
+func NewLoaderWatcher[T any](loader *Loader) *Watcher[T] {
+	return newWatcher(loader.Paths, func() (T, []string, error) {
+		paths := slices.Clone(loader.Paths)
+		fileLoader := *loader
+		fileLoader.onRead = func(path string) {
+			paths = append(paths, path)
+			if loader.TrustedKey != nil {
+				paths = append(paths, path+SignatureSuffix)
+			}
+		}
+
+		var cfg T
+		path, err := fileLoader.Load(&cfg)
+		if err != nil {
+			return cfg, nil, err
+		}
+		paths = append(paths, filepath.Join(filepath.Dir(path), ConfDir))
+		slices.Sort(paths)
+		return cfg, slices.Compact(paths), nil
+	})
+}
+
+// OnChange registers a callback invoked with the old and new config after a swap.
+func (w *Watcher[T]) OnChange(callback func(oldCfg, newCfg T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, callback)
+}
+
+// Current returns the active config.
+func (w *Watcher[T]) Current() T {
+	if cfg := w.current.Load(); cfg != nil {
+		return *cfg
+	}
+	var cfg T
+	return cfg
+}
+
+// Load loads and validates the initial config.
+func (w *Watcher[T]) Load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stamps, err := w.stat(w.paths)
+	if err != nil {
+		return err
+	}
+	cfg, paths, err := w.loadValid()
+	if err != nil {
+		return err
+	}
+	w.stamps = stamps
+	if err = w.watch(paths); err != nil {
+		return err
+	}
+	w.current.Store(&cfg)
+	return nil
+}
+
+// Check polls the files once, it returns true if the config was swapped. The
+// callbacks are invoked without holding the lock, so they may call OnChange or
+// Check.
+func (w *Watcher[T]) Check() (bool, error) {
+	w.mu.Lock()
+	oldCfg, cfg, changed, err := w.reload()
+	callbacks := slices.Clone(w.callbacks)
+	w.mu.Unlock()
+
+	if err != nil || !changed {
+		return false, err
+	}
+	for _, callback := range callbacks {
+		callback(oldCfg, cfg)
+	}
+	return true, nil
+}
+
+// reload swaps the config if the files changed, w.mu must be held.
+func (w *Watcher[T]) reload() (oldCfg T, cfg T, changed bool, err error) {
+	stamps, err := w.stat(w.paths)
+	if err != nil {
+		return oldCfg, cfg, false, err
+	}
+	if !w.changed(stamps) {
+		w.stamps = stamps
+		return oldCfg, cfg, false, nil
+	}
+	// remember the stamps even if the config is invalid, so it is not reported
+	// again until the files change
+	w.stamps = stamps
+
+	var paths []string
+	if cfg, paths, err = w.loadValid(); err != nil {
+		return oldCfg, cfg, false, fmt.Errorf("keep previous config: %w", err)
+	}
+	if err = w.watch(paths); err != nil {
+		return oldCfg, cfg, false, err
+	}
+
+	oldCfg = w.Current()
+	w.current.Store(&cfg)
+	return oldCfg, cfg, true, nil
+}
+
+// Start loads the initial config and polls the files every Interval until Stop.
+func (w *Watcher[T]) Start() error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("invalid watch interval %s", w.Interval)
+	}
+	if err := w.Load(); err != nil {
+		return err
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := w.Check(); err != nil {
+					log.Errorf("failed to reload config: %v", err)
+				}
+			}
+		}
+	}(w.stop, w.done)
+	return nil
+}
+
+// Stop stops polling, it waits for a running Check and its callbacks.
+func (w *Watcher[T]) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+func (w *Watcher[T]) loadValid() (T, []string, error) {
+	cfg, paths, err := w.load()
+	if err != nil {
+		return cfg, nil, err
+	}
+	if w.Validate != nil {
+		if err = w.Validate(cfg); err != nil {
+			return cfg, nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return cfg, paths, nil
+}
+
+// watch replaces the watched paths if paths isn't nil. Only the new paths are
+// stamped, so a change of the others after they were stamped is still seen.
+func (w *Watcher[T]) watch(paths []string) error {
+	if paths == nil {
+		return nil
+	}
+	var added []string
+	for _, path := range paths {
+		if _, ok := w.stamps[path]; !ok {
+			added = append(added, path)
+		}
+	}
+	stamps, err := w.stat(added)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if stamp, ok := w.stamps[path]; ok {
+			stamps[path] = stamp
+		}
+	}
+	w.paths = paths
+	w.stamps = stamps
+	return nil
+}
+
+// changed compares the content hashes, a touched file is not a change.
+func (w *Watcher[T]) changed(stamps map[string]fileStamp) bool {
+	for path, stamp := range stamps {
+		prev := w.stamps[path]
+		if prev.exists != stamp.exists || prev.hash != stamp.hash {
+			return true
+		}
+	}
+	return false
+}
+
+// stat returns the stamps of paths, a file is only hashed if its mtime or size
+// changed and a dir by the names of its entries.
+func (w *Watcher[T]) stat(paths []string) (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				stamps[path] = fileStamp{}
+				continue
+			}
+			return nil, err
+		}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.New()
+			for _, entry := range entries {
+				hash.Write([]byte(entry.Name() + "\n"))
+			}
+			stamp := fileStamp{exists: true}
+			hash.Sum(stamp.hash[:0])
+			stamps[path] = stamp
+			continue
+		}
+
+		stamp := fileStamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+		prev := w.stamps[path]
+		if prev.exists && prev.modTime.Equal(stamp.modTime) && prev.size == stamp.size {
+			stamps[path] = prev
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		stamp.hash = sha256.Sum256(data)
+		stamps[path] = stamp
+	}
+	return stamps, nil
+}
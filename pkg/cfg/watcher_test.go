@@ -0,0 +1,227 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhaojunlucky/golib/pkg/security"
+)
+
+func validateTestCfg(cfg testCfg) error {
+	if cfg.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func TestWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: v1\nport: 1\n")
+
+	w := NewFileWatcher[testCfg](filepath.Join(dir, "missing.yaml"), path)
+	w.Validate = validateTestCfg
+
+	var oldCfg, newCfg testCfg
+	calls := 0
+	w.OnChange(func(o, n testCfg) {
+		oldCfg, newCfg = o, n
+		calls++
+	})
+
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Current().Name != "v1" {
+		t.Fatalf("unexpected config %+v", w.Current())
+	}
+
+	if changed, err := w.Check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v %v", changed, err)
+	}
+
+	// touching the file without changing the content is not a change
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := w.Check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v %v", changed, err)
+	}
+
+	writeTestFile(t, path, "name: v2\nport: 2\n")
+	if changed, err := w.Check(); err != nil || !changed {
+		t.Fatalf("expected change, got %v %v", changed, err)
+	}
+	if calls != 1 || oldCfg.Name != "v1" || newCfg.Name != "v2" || w.Current().Name != "v2" {
+		t.Errorf("unexpected callback %d %+v %+v", calls, oldCfg, newCfg)
+	}
+
+	// invalid config keeps the previous one
+	writeTestFile(t, path, "name: v3\nport: 0\n")
+	if changed, err := w.Check(); err == nil || changed {
+		t.Fatalf("expected invalid config, got %v %v", changed, err)
+	} else {
+		t.Log(err)
+	}
+	if calls != 1 || w.Current().Name != "v2" {
+		t.Errorf("expected previous config, got %+v", w.Current())
+	}
+
+	// the invalid content is not reported again
+	if changed, err := w.Check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v %v", changed, err)
+	}
+}
+
+func TestWatcher_LoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: v1\n")
+
+	w := NewFileWatcher[testCfg](path)
+	w.Validate = validateTestCfg
+	if err := w.Load(); err == nil {
+		t.Fatal("expect to fail")
+	}
+}
+
+func TestWatcher_StartStop(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: v1\nport: 1\n")
+
+	w := NewFileWatcher[testCfg](path)
+	w.Interval = 10 * time.Millisecond
+
+	changed := make(chan testCfg, 1)
+	w.OnChange(func(_, n testCfg) {
+		changed <- n
+	})
+
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	writeTestFile(t, path, "name: v2\nport: 2\n")
+	select {
+	case cfg := <-changed:
+		if cfg.Name != "v2" {
+			t.Errorf("unexpected config %+v", cfg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for config change")
+	}
+}
+
+func TestWatcher_CallbackReentrant(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: v1\nport: 1\n")
+
+	w := NewFileWatcher[testCfg](path)
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	w.OnChange(func(_, _ testCfg) {
+		w.OnChange(func(_, _ testCfg) {})
+		_, err := w.Check()
+		done <- err
+	})
+
+	writeTestFile(t, path, "name: v2\nport: 2\n")
+	go func() {
+		if _, err := w.Check(); err != nil {
+			done <- err
+		}
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback deadlocked")
+	}
+}
+
+func TestWatcher_InvalidInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "name: v1\nport: 1\n")
+
+	w := NewFileWatcher[testCfg](path)
+	w.Interval = 0
+	if err := w.Start(); err == nil {
+		w.Stop()
+		t.Fatal("expected error for zero interval")
+	}
+}
+
+func TestWatcher_ReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	serverPath := filepath.Join(dir, "server.yaml")
+	writeTestFile(t, path, "name: v1\nserver: !include server.yaml\n")
+	writeTestFile(t, serverPath, "port: 1\n")
+	writeTestFile(t, filepath.Join(dir, ConfDir, "10.yaml"), "name: confd\n")
+
+	type serverCfg struct {
+		Name   string  `yaml:"name"`
+		Server testCfg `yaml:"server"`
+	}
+	w := NewFileWatcher[serverCfg](path)
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := w.Current(); cfg.Name != "confd" || cfg.Server.Port != 1 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	for _, step := range []struct {
+		path    string
+		content string
+		check   func(cfg serverCfg) bool
+	}{
+		{serverPath, "port: 2\n", func(cfg serverCfg) bool { return cfg.Server.Port == 2 }},
+		{filepath.Join(dir, ConfDir, "10.yaml"), "name: fragment\n", func(cfg serverCfg) bool { return cfg.Name == "fragment" }},
+		{filepath.Join(dir, ConfDir, "20.yaml"), "name: new fragment\n", func(cfg serverCfg) bool { return cfg.Name == "new fragment" }},
+	} {
+		writeTestFile(t, step.path, step.content)
+		if changed, err := w.Check(); err != nil || !changed {
+			t.Fatalf("%s: expected change, got %v %v", step.path, changed, err)
+		}
+		if cfg := w.Current(); !step.check(cfg) {
+			t.Errorf("%s: unexpected config %+v", step.path, cfg)
+		}
+	}
+}
+
+func TestWatcher_LoaderSecrets(t *testing.T) {
+	key, err := security.GenerateECKeyPair("secp256r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := EncryptSecret(&key.PublicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "name: !secret "+password+"\nport: 1\n")
+
+	loader := NewLoader(path)
+	loader.Secrets = NewECIESSecrets(key)
+	w := NewLoaderWatcher[testCfg](loader)
+	if err = w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := w.Current(); cfg.Name != "s3cret" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
@@ -0,0 +1,223 @@
+package collection
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// converter converts the values of a map tree to typed values. Numbers convert
+// between all number types if the value fits, see convertNumber. If weak is
+// set, strings are parsed as bools, numbers and durations, numbers and bools
+// are formatted as strings and a single value becomes a slice of one item.
+type converter struct {
+	weak bool
+}
+
+// Get returns the value at path converted to T, see GetPath. Structs are
+// decoded like Decode.
+func Get[T any](m *MapWrapper, path string) (T, error) {
+	var out T
+	value, err := m.GetPath(path)
+	if err != nil {
+		return out, err
+	}
+
+	converted, err := m.converter().convert(path, value, reflect.TypeFor[T]())
+	if err != nil {
+		return out, err
+	}
+	return converted.Interface().(T), nil
+}
+
+// GetOr is like Get but returns def if the path doesn't exist, is null or can't
+// be converted.
+func GetOr[T any](m *MapWrapper, path string, def T) T {
+	if value, err := m.GetPath(path); err != nil || value == nil {
+		return def
+	}
+	out, err := Get[T](m, path)
+	if err != nil {
+		return def
+	}
+	return out
+}
+
+func (m *MapWrapper) GetString(path string, def string) string {
+	return GetOr(m, path, def)
+}
+
+func (m *MapWrapper) GetInt(path string, def int) int {
+	return GetOr(m, path, def)
+}
+
+func (m *MapWrapper) GetBool(path string, def bool) bool {
+	return GetOr(m, path, def)
+}
+
+// GetDuration returns the duration at path, numbers are nanoseconds and with
+// WeakCoercion strings like 5s are parsed.
+func (m *MapWrapper) GetDuration(path string, def time.Duration) time.Duration {
+	return GetOr(m, path, def)
+}
+
+func (m *MapWrapper) GetStringSlice(path string, def []string) []string {
+	return GetOr(m, path, def)
+}
+
+func (m *MapWrapper) converter() converter {
+	return converter{weak: m.WeakCoercion}
+}
+
+func (c converter) convert(path string, value any, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, pathErr(path, fmt.Errorf("null can't be converted to %s", t))
+	}
+
+	src := reflect.ValueOf(value)
+	if t == durationType {
+		return c.convertDuration(path, src)
+	}
+	if src.Type().AssignableTo(t) {
+		return src, nil
+	}
+
+	fail := pathErr(path, fmt.Errorf("can't convert %T to %s", value, t))
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		switch {
+		case src.Kind() == reflect.Bool:
+			out.SetBool(src.Bool())
+		case c.weak && src.Kind() == reflect.String:
+			b, err := strconv.ParseBool(src.String())
+			if err != nil {
+				return reflect.Value{}, fail
+			}
+			out.SetBool(b)
+		default:
+			return reflect.Value{}, fail
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if c.weak && src.Kind() == reflect.String {
+			f, err := strconv.ParseFloat(src.String(), 64)
+			if err != nil {
+				return reflect.Value{}, fail
+			}
+			if i, err := strconv.ParseInt(src.String(), 10, 64); err == nil {
+				src = reflect.ValueOf(i)
+			} else {
+				src = reflect.ValueOf(f)
+			}
+		}
+		if !isNumber(src.Kind()) {
+			return reflect.Value{}, fail
+		}
+		converted, err := convertNumber(src, t)
+		if err != nil {
+			return reflect.Value{}, pathErr(path, err)
+		}
+		out.Set(converted)
+	case reflect.String:
+		switch {
+		case src.Kind() == reflect.String:
+			out.SetString(src.String())
+		case c.weak && src.Kind() == reflect.Bool:
+			out.SetString(strconv.FormatBool(src.Bool()))
+		case c.weak && isNumber(src.Kind()):
+			out.SetString(formatNumber(src))
+		default:
+			return reflect.Value{}, fail
+		}
+	case reflect.Slice, reflect.Array:
+		if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+			if !c.weak || t.Kind() != reflect.Slice {
+				return reflect.Value{}, fail
+			}
+			src = reflect.ValueOf([]any{value})
+		}
+		if t.Kind() == reflect.Array && src.Len() != t.Len() {
+			return reflect.Value{}, pathErr(path, fmt.Errorf("expected %d items, got %d", t.Len(), src.Len()))
+		}
+		if t.Kind() == reflect.Slice {
+			out.Set(reflect.MakeSlice(t, src.Len(), src.Len()))
+		}
+		for i := 0; i < src.Len(); i++ {
+			item, err := c.convert(indexPath(path, i), src.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(item)
+		}
+	case reflect.Map:
+		if src.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+			return reflect.Value{}, fail
+		}
+		data, err := toStringMap(value)
+		if err != nil {
+			return reflect.Value{}, fail
+		}
+		out.Set(reflect.MakeMapWithSize(t, len(data)))
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			item, err := c.convert(keyPath(path, key), data[key], t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), item)
+		}
+	case reflect.Pointer:
+		elem, err := c.convert(path, value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.Set(reflect.New(t.Elem()))
+		out.Elem().Set(elem)
+	case reflect.Struct:
+		if err := NewDecoder().decode(path, value, out.Addr().Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	default:
+		return reflect.Value{}, fail
+	}
+	return out, nil
+}
+
+func (c converter) convertDuration(path string, src reflect.Value) (reflect.Value, error) {
+	switch {
+	case src.Type() == durationType:
+		return src, nil
+	case isNumber(src.Kind()):
+		d, err := convertNumber(src, durationType)
+		if err != nil {
+			return reflect.Value{}, pathErr(path, err)
+		}
+		return d, nil
+	case c.weak && src.Kind() == reflect.String:
+		d, err := time.ParseDuration(src.String())
+		if err != nil {
+			return reflect.Value{}, pathErr(path, err)
+		}
+		return reflect.ValueOf(d), nil
+	}
+	return reflect.Value{}, pathErr(path, fmt.Errorf("can't convert %s to %s", src.Type(), durationType))
+}
+
+func formatNumber(val reflect.Value) string {
+	switch {
+	case isInt(val.Kind()):
+		return strconv.FormatInt(val.Int(), 10)
+	case isUint(val.Kind()):
+		return strconv.FormatUint(val.Uint(), 10)
+	default:
+		return strconv.FormatFloat(val.Float(), 'f', -1, val.Type().Bits())
+	}
+}
@@ -0,0 +1,148 @@
+package collection
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConvertYAML = `
+name: app
+port: 8080
+ratio: 0.5
+whole: 3.0
+enabled: true
+timeout: 5000000000
+tags: [a, b]
+str:
+  port: "10"
+  ratio: "0.25"
+  enabled: "true"
+  timeout: 5s
+  tag: single
+  number: 42
+  flag: false
+servers:
+  main:
+    host: a
+    port: 80
+empty:
+`
+
+func TestMapWrapper_TypedGetters(t *testing.T) {
+	m := newTestDecodeWrapper(t, testConvertYAML)
+
+	if v := m.GetString("name", "def"); v != "app" {
+		t.Errorf("GetString = %s", v)
+	}
+	if v := m.GetInt("port", 0); v != 8080 {
+		t.Errorf("GetInt = %d", v)
+	}
+	if v := m.GetInt("whole", 0); v != 3 {
+		t.Errorf("GetInt float = %d", v)
+	}
+	if v := m.GetBool("enabled", false); !v {
+		t.Error("GetBool = false")
+	}
+	if v := m.GetDuration("timeout", 0); v != 5*time.Second {
+		t.Errorf("GetDuration = %s", v)
+	}
+	if v := m.GetStringSlice("tags", nil); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("GetStringSlice = %v", v)
+	}
+
+	// defaults for missing, null and unconvertible values
+	if v := m.GetString("missing", "def"); v != "def" {
+		t.Errorf("GetString missing = %s", v)
+	}
+	if v := m.GetInt("empty", 7); v != 7 {
+		t.Errorf("GetInt null = %d", v)
+	}
+	for _, path := range []string{"str.port", "str.enabled", "str.timeout", "str.tag"} {
+		if v := m.GetInt(path, -1); v != -1 {
+			t.Errorf("GetInt %s = %d without weak coercion", path, v)
+		}
+	}
+	if v := m.GetBool("str.enabled", false); v {
+		t.Error("expected no string to bool without weak coercion")
+	}
+	if v := m.GetDuration("str.timeout", time.Minute); v != time.Minute {
+		t.Errorf("expected no string to duration without weak coercion, got %s", v)
+	}
+	if v := m.GetString("str.number", "def"); v != "def" {
+		t.Errorf("expected no number to string without weak coercion, got %s", v)
+	}
+}
+
+func TestMapWrapper_WeakCoercion(t *testing.T) {
+	m := newTestDecodeWrapper(t, testConvertYAML)
+	m.WeakCoercion = true
+
+	if v := m.GetInt("str.port", 0); v != 10 {
+		t.Errorf("GetInt = %d", v)
+	}
+	if v := GetOr(m, "str.ratio", 0.0); v != 0.25 {
+		t.Errorf("GetOr float = %v", v)
+	}
+	if v := m.GetBool("str.enabled", false); !v {
+		t.Error("GetBool = false")
+	}
+	if v := m.GetDuration("str.timeout", 0); v != 5*time.Second {
+		t.Errorf("GetDuration = %s", v)
+	}
+	if v := m.GetStringSlice("str.tag", nil); !reflect.DeepEqual(v, []string{"single"}) {
+		t.Errorf("GetStringSlice = %v", v)
+	}
+	if v := m.GetString("str.number", ""); v != "42" {
+		t.Errorf("GetString number = %s", v)
+	}
+	if v := m.GetString("str.flag", ""); v != "false" {
+		t.Errorf("GetString bool = %s", v)
+	}
+	if v := m.GetBool("name", true); !v {
+		t.Error("expected default for unparsable bool")
+	}
+}
+
+func TestGet(t *testing.T) {
+	m := newTestDecodeWrapper(t, testConvertYAML)
+
+	type server struct {
+		Host string `cfg:"host"`
+		Port int    `cfg:"port"`
+	}
+	servers, err := Get[map[string]server](m, "servers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(servers, map[string]server{"main": {Host: "a", Port: 80}}) {
+		t.Errorf("unexpected servers %v", servers)
+	}
+
+	if port, err := Get[*uint16](m, "servers.main.port"); err != nil || *port != 80 {
+		t.Errorf("unexpected port %v, %v", port, err)
+	}
+	if tags, err := Get[[2]string](m, "tags"); err != nil || tags != [2]string{"a", "b"} {
+		t.Errorf("unexpected tags %v, %v", tags, err)
+	}
+	if value, err := Get[any](m, "name"); err != nil || value != "app" {
+		t.Errorf("unexpected value %v, %v", value, err)
+	}
+
+	if _, err = Get[[]int](m, "tags"); err == nil || !strings.Contains(err.Error(), "tags[0]: can't convert string to int") {
+		t.Errorf("expected path-qualified error, got %v", err)
+	}
+	m = NewMapWrapper(map[string]any{"ports": map[any]any{"http": 80, 443: 8443}})
+	if ports, err := Get[map[string]int](m, "ports"); err != nil || !reflect.DeepEqual(ports, map[string]int{"http": 80, "443": 8443}) {
+		t.Errorf("unexpected ports %v, %v", ports, err)
+	}
+	m = newTestDecodeWrapper(t, testConvertYAML)
+
+	if _, err = Get[int](m, "empty"); err == nil {
+		t.Error("expected error for null")
+	}
+	if _, err = Get[int](m, "missing"); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
@@ -0,0 +1,323 @@
+package collection
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CfgTag is the struct tag naming the map key of a field, e.g. `cfg:"name"`.
+// Fields without it match the key equal to the field name, ignoring case, and
+// `cfg:"-"` skips a field. Embedded structs without a name are flattened.
+const CfgTag = "cfg"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decoder fills structs, slices, maps and scalars from a tree of maps and
+// slices as decoded from YAML or JSON. If ErrorUnknown is set, keys without a
+// matching struct field are errors.
+type Decoder struct {
+	Tag          string
+	ErrorUnknown bool
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{Tag: CfgTag}
+}
+
+// Decode decodes the wrapped map into out, which must be a pointer.
+func (m *MapWrapper) Decode(out any) error {
+	return NewDecoder().Decode(m.data, out)
+}
+
+// DecodeStrict is like Decode but unknown keys are errors.
+func (m *MapWrapper) DecodeStrict(out any) error {
+	decoder := NewDecoder()
+	decoder.ErrorUnknown = true
+	return decoder.Decode(m.data, out)
+}
+
+// DecodePath decodes the value at path into out, see GetPath.
+func (m *MapWrapper) DecodePath(path string, out any) error {
+	value, err := m.GetPath(path)
+	if err != nil {
+		return err
+	}
+	return NewDecoder().decode(path, value, out)
+}
+
+// Decode decodes data into out, which must be a pointer. All errors are
+// returned joined, each prefixed by the path of the value.
+func (d *Decoder) Decode(data any, out any) error {
+	return d.decode("", data, out)
+}
+
+func (d *Decoder) decode(path string, data any, out any) error {
+	val := reflect.ValueOf(out)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return fmt.Errorf("out must be a non-nil pointer, got %T", out)
+	}
+
+	var errs []error
+	d.decodeValue(path, data, val.Elem(), &errs)
+	return errors.Join(errs...)
+}
+
+func (d *Decoder) decodeValue(path string, src any, dst reflect.Value, errs *[]error) {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		d.decodeValue(path, src, dst.Elem(), errs)
+		return
+	}
+
+	if text, ok := src.(string); ok && dst.CanAddr() {
+		if unmarshaler, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := unmarshaler.UnmarshalText([]byte(text)); err != nil {
+				*errs = append(*errs, pathErr(path, err))
+			}
+			return
+		}
+	}
+
+	srcVal := reflect.ValueOf(src)
+	switch dst.Kind() {
+	case reflect.Struct:
+		d.decodeStruct(path, srcVal, dst, errs)
+	case reflect.Slice:
+		if srcVal.Kind() != reflect.Slice && srcVal.Kind() != reflect.Array {
+			*errs = append(*errs, pathErr(path, fmt.Errorf("expected a slice, got %T", src)))
+			return
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), srcVal.Len(), srcVal.Len()))
+		for i := 0; i < srcVal.Len(); i++ {
+			d.decodeValue(indexPath(path, i), srcVal.Index(i).Interface(), dst.Index(i), errs)
+		}
+	case reflect.Array:
+		if srcVal.Kind() != reflect.Slice && srcVal.Kind() != reflect.Array {
+			*errs = append(*errs, pathErr(path, fmt.Errorf("expected a slice, got %T", src)))
+			return
+		}
+		if srcVal.Len() != dst.Len() {
+			*errs = append(*errs, pathErr(path, fmt.Errorf("expected %d items, got %d", dst.Len(), srcVal.Len())))
+			return
+		}
+		for i := 0; i < srcVal.Len(); i++ {
+			d.decodeValue(indexPath(path, i), srcVal.Index(i).Interface(), dst.Index(i), errs)
+		}
+	case reflect.Map:
+		if srcVal.Kind() != reflect.Map {
+			*errs = append(*errs, pathErr(path, fmt.Errorf("expected a map, got %T", src)))
+			return
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			*errs = append(*errs, pathErr(path, fmt.Errorf("unsupported map key type %s", dst.Type().Key())))
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), srcVal.Len()))
+		for _, key := range sortedKeys(srcVal) {
+			// non-string keys, e.g. of a map[any]any, are formatted like convertMap
+			name := fmt.Sprint(key.Interface())
+			item := reflect.New(dst.Type().Elem()).Elem()
+			d.decodeValue(keyPath(path, name), srcVal.MapIndex(key).Interface(), item, errs)
+			dst.SetMapIndex(reflect.ValueOf(name).Convert(dst.Type().Key()), item)
+		}
+	default:
+		if err := setScalar(srcVal, dst); err != nil {
+			*errs = append(*errs, pathErr(path, err))
+		}
+	}
+}
+
+func (d *Decoder) decodeStruct(path string, srcVal reflect.Value, dst reflect.Value, errs *[]error) {
+	data, err := toStringMap(srcVal.Interface())
+	if err != nil {
+		*errs = append(*errs, pathErr(path, fmt.Errorf("expected a map, got %s", srcVal.Type())))
+		return
+	}
+
+	fields := d.structFields(dst.Type())
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		field, ok := matchField(fields, key)
+		if !ok {
+			if d.ErrorUnknown {
+				*errs = append(*errs, pathErr(keyPath(path, key), fmt.Errorf("unknown key")))
+			}
+			continue
+		}
+		d.decodeValue(keyPath(path, key), data[key], fieldByIndex(dst, field.index), errs)
+	}
+}
+
+type structField struct {
+	name   string
+	tagged bool
+	index  []int
+}
+
+type fieldsKey struct {
+	tag string
+	t   reflect.Type
+}
+
+// fieldCache holds the []structField of every decoded struct type and tag.
+var fieldCache sync.Map
+
+// structFields returns the decodable fields of t, the fields of embedded
+// structs are flattened unless the embedded field is named by the tag.
+func (d *Decoder) structFields(t reflect.Type) []structField {
+	tag := d.Tag
+	if tag == "" {
+		tag = CfgTag
+	}
+
+	key := fieldsKey{tag: tag, t: t}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.([]structField)
+	}
+	fields := typeFields(t, tag, nil)
+	fieldCache.Store(key, fields)
+	return fields
+}
+
+// typeFields returns the fields of t, visiting holds the embedding structs so a
+// struct embedding itself is not flattened again.
+func typeFields(t reflect.Type, tag string, visiting []reflect.Type) []structField {
+	visiting = append(visiting, t)
+
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			if !field.IsExported() && field.Type.Kind() == reflect.Pointer {
+				// an unexported embedded pointer can't be allocated
+				continue
+			}
+			if slices.Contains(visiting, embedded) {
+				continue
+			}
+			for _, inner := range typeFields(embedded, tag, visiting) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			fields = append(fields, structField{name: field.Name, index: []int{i}})
+		} else {
+			fields = append(fields, structField{name: name, tagged: true, index: []int{i}})
+		}
+	}
+	// fields of the outer struct shadow the embedded ones
+	slices.SortStableFunc(fields, func(a, b structField) int {
+		return len(a.index) - len(b.index)
+	})
+	return fields
+}
+
+// matchField returns the field of the key, tagged names match exactly and
+// field names ignoring case.
+func matchField(fields []structField, key string) (structField, bool) {
+	for _, field := range fields {
+		if field.name == key {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if !field.tagged && strings.EqualFold(field.name, key) {
+			return field, true
+		}
+	}
+	return structField{}, false
+}
+
+// fieldByIndex returns the nested field, nil embedded pointers are allocated.
+func fieldByIndex(val reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val
+}
+
+// setScalar sets dst to src if it is assignable or can be converted without
+// loss, see convertStrict, and parses durations.
+func setScalar(src reflect.Value, dst reflect.Value) error {
+	if dst.Type() == durationType && src.Kind() == reflect.String {
+		duration, err := time.ParseDuration(src.String())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(duration))
+		return nil
+	}
+
+	converted, err := convertStrict(src, dst.Type())
+	if err != nil {
+		return err
+	}
+	dst.Set(converted)
+	return nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return isInt(kind) || isUint(kind) || isFloat(kind)
+}
+
+// sortedKeys returns the keys of a map with string keys in order, so errors are
+// reported deterministically.
+func sortedKeys(mapVal reflect.Value) []reflect.Value {
+	keys := mapVal.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	})
+	return keys
+}
+
+func keyPath(path string, key string) string {
+	if path == "" {
+		return escapeKey(key)
+	}
+	return path + "." + escapeKey(key)
+}
+
+func indexPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+func pathErr(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
@@ -0,0 +1,212 @@
+package collection
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type testTLSCfg struct {
+	Cert string `cfg:"cert"`
+	Key  string `cfg:"key"`
+}
+
+type testBaseCfg struct {
+	Name    string `cfg:"name"`
+	Verbose bool
+}
+
+type testBackendCfg struct {
+	Host   string        `cfg:"host"`
+	Port   uint16        `cfg:"port"`
+	Weight float64       `cfg:"weight"`
+	Addr   net.IP        `cfg:"addr"`
+	Wait   time.Duration `cfg:"wait"`
+}
+
+type testDecodeCfg struct {
+	testBaseCfg
+	TLS      *testTLSCfg                `cfg:"tls"`
+	Backends []testBackendCfg           `cfg:"backends"`
+	Named    map[string]*testBackendCfg `cfg:"named"`
+	Ports    [2]int                     `cfg:"ports"`
+	Extra    map[string]any             `cfg:"extra"`
+	Skipped  string                     `cfg:"-"`
+	internal string
+}
+
+const testDecodeYAML = `
+name: app
+verbose: true
+tls:
+  cert: a.pem
+  key: a.key
+backends:
+  - host: a
+    port: 80
+    weight: 1
+    addr: 10.0.0.1
+    wait: 5s
+  - host: b
+    port: 81
+    weight: 0.5
+named:
+  main:
+    host: m
+    port: 8080
+ports: [80, 443]
+extra:
+  anything: [1, 2]
+`
+
+func newTestDecodeWrapper(t *testing.T, content string) *MapWrapper {
+	var obj map[string]any
+	if err := yaml.Unmarshal([]byte(content), &obj); err != nil {
+		t.Fatal(err)
+	}
+	return NewMapWrapper(obj)
+}
+
+func TestMapWrapper_Decode(t *testing.T) {
+	m := newTestDecodeWrapper(t, testDecodeYAML)
+
+	var cfg testDecodeCfg
+	if err := m.DecodeStrict(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testDecodeCfg{
+		testBaseCfg: testBaseCfg{Name: "app", Verbose: true},
+		TLS:         &testTLSCfg{Cert: "a.pem", Key: "a.key"},
+		Backends: []testBackendCfg{
+			{Host: "a", Port: 80, Weight: 1, Addr: net.ParseIP("10.0.0.1"), Wait: 5 * time.Second},
+			{Host: "b", Port: 81, Weight: 0.5},
+		},
+		Named: map[string]*testBackendCfg{"main": {Host: "m", Port: 8080}},
+		Ports: [2]int{80, 443},
+		Extra: map[string]any{"anything": []any{1, 2}},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %+v, got %+v", expected, cfg)
+	}
+
+	var tls testTLSCfg
+	if err := m.DecodePath("tls", &tls); err != nil || tls.Cert != "a.pem" {
+		t.Errorf("unexpected tls %+v, %v", tls, err)
+	}
+	var port int
+	if err := m.DecodePath("backends[1].port", &port); err != nil || port != 81 {
+		t.Errorf("unexpected port %d, %v", port, err)
+	}
+}
+
+func TestMapWrapper_DecodeEmbeddedPointer(t *testing.T) {
+	type Base struct {
+		Name    string `cfg:"name"`
+		Verbose bool
+	}
+	type outer struct {
+		*Base
+		Name string `cfg:"name"`
+	}
+
+	var cfg outer
+	m := NewMapWrapper(map[string]any{"name": "outer", "verbose": true})
+	if err := m.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "outer" || cfg.Base == nil || !cfg.Verbose || cfg.Base.Name != "" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+type RecursiveCfg struct {
+	*RecursiveCfg
+	Name string `cfg:"name"`
+}
+
+func TestMapWrapper_DecodeRecursiveEmbedded(t *testing.T) {
+	var cfg RecursiveCfg
+	m := NewMapWrapper(map[string]any{"name": "rec"})
+	if err := m.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "rec" || cfg.RecursiveCfg != nil {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestMapWrapper_DecodeAnyKeys(t *testing.T) {
+	var cfg struct {
+		M map[string]int
+	}
+	m := NewMapWrapper(map[string]any{"m": map[any]any{"a": 1, 2: 3}})
+	if err := m.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.M, map[string]int{"a": 1, "2": 3}) {
+		t.Errorf("unexpected map %v", cfg.M)
+	}
+
+	m = NewMapWrapper(map[string]any{"m": map[any]any{"a": "one"}})
+	if err := m.Decode(&cfg); err == nil || !strings.Contains(err.Error(), "m.a: ") {
+		t.Errorf("expected error at m.a, got %v", err)
+	}
+}
+
+func TestMapWrapper_DecodeErrors(t *testing.T) {
+	m := newTestDecodeWrapper(t, `
+name: [not, a, string]
+tls:
+  cert: a.pem
+  kye: typo
+backends:
+  - host: a
+    port: eighty
+    wait: soon
+named: []
+ports: [1, 2, 3]
+unknown: true
+`)
+
+	var cfg testDecodeCfg
+	if err := m.Decode(&cfg); err == nil {
+		t.Fatal("expected errors")
+	} else if strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected unknown keys to be ignored, got %v", err)
+	}
+
+	err := m.DecodeStrict(&cfg)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, msg := range []string{
+		"backends[0].port: can't convert string to uint16",
+		"backends[0].wait: time: invalid duration",
+		"name: can't convert []interface {} to string",
+		"named: expected a map",
+		"ports: expected 2 items, got 3",
+		"tls.kye: unknown key",
+		"unknown: unknown key",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in\n%v", msg, err)
+		}
+	}
+	if cfg.TLS == nil || cfg.TLS.Cert != "a.pem" {
+		t.Errorf("expected the valid keys to be decoded, got %+v", cfg.TLS)
+	}
+
+	if err = m.Decode(cfg); err == nil {
+		t.Error("expected error for non-pointer")
+	}
+	var pathErr *PathError
+	if err = m.DecodePath("tls.missing", &cfg); !errors.As(err, &pathErr) {
+		t.Errorf("expected PathError, got %v", err)
+	}
+}
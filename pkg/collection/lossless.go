@@ -0,0 +1,154 @@
+package collection
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// convertNumber converts between number types and fails if the value doesn't
+// fit into t or a fraction would be lost. Floats may round to the precision
+// of float32.
+func convertNumber(src reflect.Value, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	switch {
+	case isInt(src.Kind()):
+		return out, setFromInt(src.Int(), out)
+	case isUint(src.Kind()):
+		return out, setFromUint(src.Uint(), out)
+	case isFloat(src.Kind()):
+		return out, setFromFloat(src.Float(), out)
+	}
+	return reflect.Value{}, fmt.Errorf("can't convert %s to %s", src.Type(), t)
+}
+
+func setFromInt(v int64, out reflect.Value) error {
+	switch {
+	case isInt(out.Kind()):
+		if out.OverflowInt(v) {
+			return overflowErr(v, out.Type())
+		}
+		out.SetInt(v)
+	case isUint(out.Kind()):
+		if v < 0 || out.OverflowUint(uint64(v)) {
+			return overflowErr(v, out.Type())
+		}
+		out.SetUint(uint64(v))
+	case isFloat(out.Kind()):
+		f := roundFloat(float64(v), out.Type())
+		if f >= math.MaxInt64 || int64(f) != v {
+			return fmt.Errorf("%d can't be represented exactly as %s", v, out.Type())
+		}
+		out.SetFloat(f)
+	default:
+		return fmt.Errorf("can't convert int64 to %s", out.Type())
+	}
+	return nil
+}
+
+func setFromUint(v uint64, out reflect.Value) error {
+	switch {
+	case isInt(out.Kind()):
+		if v > math.MaxInt64 || out.OverflowInt(int64(v)) {
+			return overflowErr(v, out.Type())
+		}
+		out.SetInt(int64(v))
+	case isUint(out.Kind()):
+		if out.OverflowUint(v) {
+			return overflowErr(v, out.Type())
+		}
+		out.SetUint(v)
+	case isFloat(out.Kind()):
+		f := roundFloat(float64(v), out.Type())
+		if f >= math.MaxUint64 || uint64(f) != v {
+			return fmt.Errorf("%d can't be represented exactly as %s", v, out.Type())
+		}
+		out.SetFloat(f)
+	default:
+		return fmt.Errorf("can't convert uint64 to %s", out.Type())
+	}
+	return nil
+}
+
+func setFromFloat(f float64, out reflect.Value) error {
+	if isFloat(out.Kind()) {
+		if !math.IsInf(f, 0) && !math.IsNaN(f) && out.OverflowFloat(f) {
+			return overflowErr(f, out.Type())
+		}
+		out.SetFloat(f)
+		return nil
+	}
+
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("%v can't be converted to %s", f, out.Type())
+	}
+	if f != math.Trunc(f) {
+		return fmt.Errorf("%v would lose its fraction as %s", f, out.Type())
+	}
+	switch {
+	case isInt(out.Kind()):
+		if f < math.MinInt64 || f >= math.MaxInt64 || out.OverflowInt(int64(f)) {
+			return overflowErr(f, out.Type())
+		}
+		out.SetInt(int64(f))
+	case isUint(out.Kind()):
+		if f < 0 || f >= math.MaxUint64 || out.OverflowUint(uint64(f)) {
+			return overflowErr(f, out.Type())
+		}
+		out.SetUint(uint64(f))
+	default:
+		return fmt.Errorf("can't convert float64 to %s", out.Type())
+	}
+	return nil
+}
+
+// convertStrict converts src to t if it is assignable, a number which fits
+// into t, or between string and bool types of the same kind. Unlike
+// reflect.Value.Convert, ints never become strings of a rune.
+func convertStrict(src reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if src.Type().AssignableTo(t) {
+		return src, nil
+	}
+	switch {
+	case isNumber(src.Kind()) && isNumber(t.Kind()):
+		return convertNumber(src, t)
+	case src.Kind() == reflect.String && t.Kind() == reflect.String,
+		src.Kind() == reflect.Bool && t.Kind() == reflect.Bool:
+		return src.Convert(t), nil
+	case isNumber(src.Kind()) && t.Kind() == reflect.String:
+		return reflect.Value{}, fmt.Errorf("can't convert %s to %s, it would be a rune not the number", src.Type(), t)
+	}
+	return reflect.Value{}, fmt.Errorf("can't convert %s to %s", src.Type(), t)
+}
+
+// roundFloat returns f rounded to the precision of the float type t.
+func roundFloat(f float64, t reflect.Type) float64 {
+	if t.Kind() == reflect.Float32 {
+		return float64(float32(f))
+	}
+	return f
+}
+
+func overflowErr(v any, t reflect.Type) error {
+	return fmt.Errorf("%v overflows %s", v, t)
+}
+
+func isInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
@@ -0,0 +1,84 @@
+package collection
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertNumber(t *testing.T) {
+	testCases := []struct {
+		value    any
+		target   reflect.Type
+		expected any
+		err      string
+	}{
+		{2.0, reflect.TypeFor[int](), 2, ""},
+		{1.5, reflect.TypeFor[int](), nil, "lose its fraction"},
+		{300, reflect.TypeFor[int8](), nil, "overflows int8"},
+		{-1, reflect.TypeFor[uint](), nil, "overflows uint"},
+		{uint64(1 << 63), reflect.TypeFor[int64](), nil, "overflows int64"},
+		{1<<60 + 1, reflect.TypeFor[float32](), nil, "represented exactly"},
+		{1 << 20, reflect.TypeFor[float32](), float32(1 << 20), ""},
+		{1e40, reflect.TypeFor[float32](), nil, "overflows float32"},
+		{1e20, reflect.TypeFor[int64](), nil, "overflows int64"},
+		{uint8(200), reflect.TypeFor[int16](), int16(200), ""},
+	}
+	for _, tc := range testCases {
+		out, err := convertNumber(reflect.ValueOf(tc.value), tc.target)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%v to %s: expected error %q, got %v", tc.value, tc.target, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v to %s: %v", tc.value, tc.target, err)
+		} else if out.Interface() != tc.expected {
+			t.Errorf("%v to %s: expected %v, got %v", tc.value, tc.target, tc.expected, out.Interface())
+		}
+	}
+
+	if _, err := convertStrict(reflect.ValueOf(65), reflect.TypeFor[string]()); err == nil || !strings.Contains(err.Error(), "rune") {
+		t.Errorf("expected rune error, got %v", err)
+	}
+}
+
+func TestMapWrapper_StrictConversion(t *testing.T) {
+	m := NewMapWrapper(map[string]any{
+		"ratio": 1.5,
+		"big":   300,
+		"char":  65,
+		"list":  []any{1, 2.5},
+		"ports": map[string]any{"http": 80, "https": 70000},
+	})
+
+	// the legacy conversion is kept unless strict conversion is enabled
+	var i int
+	if err := m.Get("ratio", &i); err != nil || i != 1 {
+		t.Errorf("expected truncated 1, got %d, %v", i, err)
+	}
+
+	m.StrictConversion = true
+	var i8 int8
+	var s string
+	var list []int
+	var ports map[string]uint16
+	for key, out := range map[string]any{"ratio": &i, "big": &i8, "char": &s, "list": &list, "ports": &ports} {
+		if err := m.Get(key, out); err == nil || !strings.HasPrefix(err.Error(), "key "+key) {
+			t.Errorf("%s: expected error with key, got %v", key, err)
+		}
+	}
+
+	var f float64
+	if err := m.Get("big", &f); err != nil || f != 300 {
+		t.Errorf("expected 300, got %v, %v", f, err)
+	}
+
+	if _, err := Get[int8](m, "big"); err == nil || !strings.Contains(err.Error(), "big: 300 overflows int8") {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+	if _, err := Get[[]int](m, "list"); err == nil || !strings.Contains(err.Error(), "list[1]: 2.5 would lose its fraction") {
+		t.Errorf("expected fraction error, got %v", err)
+	}
+}
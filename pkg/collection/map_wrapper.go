@@ -5,7 +5,14 @@ import (
 	"reflect"
 )
 
+// MapWrapper wraps a map tree decoded from YAML or JSON. If WeakCoercion is set,
+// the typed getters parse strings like "true", "10" and "5s". If
+// StrictConversion is set, Get fails instead of truncating or wrapping numbers
+// and converting ints to runes, the newer APIs are always strict.
 type MapWrapper struct {
+	WeakCoercion     bool
+	StrictConversion bool
+
 	data map[string]any
 }
 
@@ -14,12 +21,18 @@ func (m *MapWrapper) Has(key string) bool {
 	return ok
 }
 
+// GetChild returns the map of key. Maps other than map[string]any, like the
+// map[any]any of yaml.v2, are copied with their keys formatted as strings.
 func (m *MapWrapper) GetChild(key string) (*MapWrapper, error) {
 	mapObj, ok := m.data[key]
 	if !ok {
 		return nil, fmt.Errorf("key %s not found in map", key)
 	}
-	return NewMapWrapper(mapObj.(map[string]any)), nil
+	data, err := toStringMap(mapObj)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", key, err)
+	}
+	return NewMapWrapper(data), nil
 }
 
 func (m *MapWrapper) GetAny(key string) (any, error) {
@@ -39,6 +52,9 @@ func (m *MapWrapper) GetType(key string) (*reflect.Value, error) {
 	return new(reflect.ValueOf(mapObj)), nil
 }
 
+// Get converts the value of key into val, which must point to a primitive,
+// slice, array or map. Nested slices, arrays and maps are converted
+// recursively, errors name the path of the value which failed.
 func (m *MapWrapper) Get(key string, val any) error {
 	mapObj, ok := m.data[key]
 	if !ok {
@@ -54,99 +70,119 @@ func (m *MapWrapper) Get(key string, val any) error {
 	eleType := valType.Elem()
 
 	switch eleType.Kind() {
-	case reflect.Slice, reflect.Array:
-		return m.getSlice(eleType, key, mapObj)
-	case reflect.String:
-		return m.getValue(eleType, key, mapObj)
-	case reflect.Map:
-		return m.getMap(eleType, key, mapObj)
 	case reflect.Pointer, reflect.Struct, reflect.UnsafePointer, reflect.Chan, reflect.Interface,
 		reflect.Uintptr, reflect.Complex64, reflect.Complex128, reflect.Func, reflect.Invalid:
 		return fmt.Errorf("key %s is not a primitive type/slice/map/array", key)
-	default:
-		return m.getValue(eleType, key, mapObj)
 	}
-}
-
-func (m *MapWrapper) getSlice(tgtVal reflect.Value, key string, srcObj any) error {
 
-	srcType := reflect.ValueOf(srcObj)
+	converted, err := m.convertValue(escapeKey(key), reflect.ValueOf(mapObj), eleType.Type())
+	if err != nil {
+		return err
+	}
+	eleType.Set(converted)
+	return nil
+}
 
-	if tgtVal.Type().Kind() == reflect.Array {
-		if tgtVal.Len() != srcType.Len() {
-			return fmt.Errorf("key %s source length %d != target length %d", key, srcType.Len(), tgtVal.Len())
+// convertValue converts a value of the map tree at path to t. Unless
+// StrictConversion is set, reflect.Value.Convert is used for values which
+// aren't assignable.
+func (m *MapWrapper) convertValue(path string, from reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if from.Kind() == reflect.Interface {
+		from = from.Elem()
+	}
+	if !from.IsValid() {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
 		}
-	} else {
-		tgtVal.Set(reflect.MakeSlice(tgtVal.Type(), srcType.Len(), srcType.Len()))
+		return reflect.Value{}, fmt.Errorf("key %s null can't be converted to %s", path, t)
+	}
+	if from.Type().AssignableTo(t) {
+		return from, nil
 	}
 
-	srcSlice := srcObj.([]any)
-
-	for i := 0; i < len(srcSlice); i++ {
-		to := tgtVal.Index(i)
-		from := reflect.ValueOf(srcSlice[i])
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if from.Kind() == reflect.Slice || from.Kind() == reflect.Array {
+			return m.convertSlice(path, from, t)
+		}
+		if t.Kind() == reflect.Array || t.Elem().Kind() != reflect.Uint8 {
+			return reflect.Value{}, fmt.Errorf("key %s is not a slice", path)
+		}
+	case reflect.Map:
+		if from.Kind() != reflect.Map {
+			return reflect.Value{}, fmt.Errorf("key %s is not a map", path)
+		}
+		return m.convertMap(path, from, t)
+	}
 
-		if from.Type().AssignableTo(to.Type()) {
-			to.Set(from)
-		} else if from.Type().ConvertibleTo(to.Type()) {
-			to.Set(from.Convert(to.Type()))
-		} else {
-			return fmt.Errorf("key %s %d elem %v %s can't be convert to type %s", key, i, from, from.Type().Name(), to.Type().Name())
+	switch {
+	case m.StrictConversion:
+		converted, err := convertStrict(from, t)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %w", path, err)
 		}
+		return converted, nil
+	case from.CanConvert(t):
+		return from.Convert(t), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("key %s %v %s can't be convert to type %s", path, from, from.Type(), t)
 	}
-	return nil
 }
 
-func (m *MapWrapper) getValue(tgtVal reflect.Value, key string, srcObj any) error {
-
-	srcType := reflect.ValueOf(srcObj)
-
-	if srcType.Type().AssignableTo(tgtVal.Type()) {
-		tgtVal.Set(srcType)
-	} else if srcType.Type().ConvertibleTo(tgtVal.Type()) {
-		tgtVal.Set(srcType.Convert(tgtVal.Type()))
+func (m *MapWrapper) convertSlice(path string, from reflect.Value, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	if t.Kind() == reflect.Array {
+		if t.Len() != from.Len() {
+			return reflect.Value{}, fmt.Errorf("key %s source length %d != target length %d", path, from.Len(), t.Len())
+		}
 	} else {
-		return fmt.Errorf("key %s source %s != target %s", key, srcType.Type(), tgtVal.Type())
+		out.Set(reflect.MakeSlice(t, from.Len(), from.Len()))
 	}
 
-	return nil
+	for i := 0; i < from.Len(); i++ {
+		converted, err := m.convertValue(indexPath(path, i), from.Index(i), t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.Index(i).Set(converted)
+	}
+	return out, nil
 }
 
-func (m *MapWrapper) getMap(tgtVal reflect.Value, key string, srcObj any) error {
-	tgtKeyType := tgtVal.Type().Key().String()
-	tgtValType := tgtVal.Type().Elem().String()
-
-	srcType := reflect.ValueOf(srcObj)
-
-	if srcType.Kind() != reflect.Map {
-		return fmt.Errorf("key %s is not a map", key)
+func (m *MapWrapper) convertMap(path string, from reflect.Value, t reflect.Type) (reflect.Value, error) {
+	fromKey := from.Type().Key().Kind()
+	if t.Key().Kind() != reflect.String || (fromKey != reflect.String && fromKey != reflect.Interface) {
+		return reflect.Value{}, fmt.Errorf("key %s source %s != %s", path, from.Type(), t)
 	}
 
-	srcKeyType := srcType.Type().Key().String()
-	srcValType := srcType.Type().Elem().String()
-
-	if tgtKeyType != srcKeyType || srcKeyType != "string" {
-		return fmt.Errorf("key %s source map[%s]%s != map[%s]%s", key, srcKeyType, srcValType, tgtKeyType, tgtValType)
+	out := reflect.MakeMapWithSize(t, from.Len())
+	for _, key := range sortedKeys(from) {
+		k := fmt.Sprint(key.Interface())
+		converted, err := m.convertValue(keyPath(path, k), from.MapIndex(key), t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), converted)
 	}
+	return out, nil
+}
 
-	if tgtValType == srcValType || srcType.Type().Elem().AssignableTo(tgtVal.Type().Elem()) || srcType.Type().Elem().ConvertibleTo(tgtVal.Type().Elem()) {
-		tgtVal.Set(srcType)
+// toStringMap returns value as a map[string]any, other maps are copied with
+// their keys formatted as strings.
+func toStringMap(value any) (map[string]any, error) {
+	if data, ok := value.(map[string]any); ok {
+		return data, nil
 	}
-
-	mapData := srcObj.(map[string]any)
-	tgtVal.Set(reflect.MakeMapWithSize(tgtVal.Type(), len(mapData)))
-	for k, v := range mapData {
-		vt := reflect.TypeOf(v)
-		if vt.AssignableTo(tgtVal.Type().Elem()) {
-			tgtVal.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
-		} else if vt.ConvertibleTo(tgtVal.Type().Elem()) {
-			tgtVal.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v).Convert(tgtVal.Type().Elem()))
-		} else {
-			return fmt.Errorf("key %s value %v type %s can't be convert to type %s", key, v, srcValType, tgtValType)
-		}
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Map {
+		return nil, fmt.Errorf("%T is not a map", value)
 	}
-
-	return nil
+	data := make(map[string]any, val.Len())
+	for iter := val.MapRange(); iter.Next(); {
+		data[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+	}
+	return data, nil
 }
 
 func NewMapWrapper(data map[string]any) *MapWrapper {
@@ -155,10 +191,12 @@ func NewMapWrapper(data map[string]any) *MapWrapper {
 	}
 }
 
+// NewMapWrapperAny wraps a map, maps other than map[string]any are copied, see
+// GetChild.
 func NewMapWrapperAny(data any) (*MapWrapper, error) {
-	dataMap, ok := data.(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("data is not a map[string]any")
+	dataMap, err := toStringMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("data is not a map: %w", err)
 	}
 	return &MapWrapper{
 		data: dataMap,
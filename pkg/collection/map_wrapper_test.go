@@ -2,6 +2,8 @@ package collection
 
 import (
 	"fmt"
+	"math/rand/v2"
+	"reflect"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -164,3 +166,249 @@ suites:
 	fmt.Println(target2)
 
 }
+
+func TestMapWrapper_MapShapes(t *testing.T) {
+	m := NewMapWrapper(map[string]any{
+		"v2":      map[any]any{"name": "app", 1: "one", "ports": []any{80, nil}},
+		"env":     map[string]string{"HOME": "/root"},
+		"typed":   []string{"a", "b"},
+		"array":   [2]int{1, 2},
+		"nulls":   []any{"a", nil},
+		"counts":  map[any]any{"a": 1, "b": uint8(2)},
+		"name":    "app",
+		"nothing": nil,
+	})
+
+	child, err := m.GetChild("v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := child.GetAny("name"); name != "app" {
+		t.Errorf("unexpected name %v", name)
+	}
+	if one, _ := child.GetAny("1"); one != "one" {
+		t.Errorf("unexpected int key value %v", one)
+	}
+	if child, err = m.GetChild("env"); err != nil || !child.Has("HOME") {
+		t.Errorf("unexpected env %v, %v", child, err)
+	}
+	for _, key := range []string{"name", "nothing", "typed"} {
+		if _, err = m.GetChild(key); err == nil {
+			t.Errorf("%s: expected error", key)
+		}
+	}
+
+	if value, err := m.GetPath("v2.ports[0]"); err != nil || value != 80 {
+		t.Errorf("unexpected path value %v, %v", value, err)
+	}
+	if value, err := m.GetPath("v2.1"); err != nil || value != "one" {
+		t.Errorf("unexpected path value %v, %v", value, err)
+	}
+
+	var strs []string
+	if err = m.Get("typed", &strs); err != nil || !reflect.DeepEqual(strs, []string{"a", "b"}) {
+		t.Errorf("unexpected typed slice %v, %v", strs, err)
+	}
+	var ints [2]int64
+	if err = m.Get("array", &ints); err != nil || ints != [2]int64{1, 2} {
+		t.Errorf("unexpected array %v, %v", ints, err)
+	}
+	var anys []any
+	if err = m.Get("nulls", &anys); err != nil || !reflect.DeepEqual(anys, []any{"a", nil}) {
+		t.Errorf("unexpected nulls %v, %v", anys, err)
+	}
+	if err = m.Get("nulls", &strs); err == nil {
+		t.Error("expected error for null string")
+	}
+	var counts map[string]int
+	if err = m.Get("counts", &counts); err != nil || !reflect.DeepEqual(counts, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("unexpected counts %v, %v", counts, err)
+	}
+	var env map[string]string
+	if err = m.Get("env", &env); err != nil || env["HOME"] != "/root" {
+		t.Errorf("unexpected env %v, %v", env, err)
+	}
+	if err = m.Get("name", &strs); err == nil {
+		t.Error("expected error for string as slice")
+	}
+	if err = m.Get("name", &env); err == nil {
+		t.Error("expected error for string as map")
+	}
+}
+
+func TestMapWrapper_GetNested(t *testing.T) {
+	var obj map[string]any
+	err := yaml.Unmarshal([]byte(`
+limits:
+  - cpu: 1
+    mem: 512
+  - cpu: 2
+    mem: 1024
+matrix:
+  - [a, b]
+  - [c]
+groups:
+  admin: [alice, bob]
+  dev: []
+grid:
+  - [1, 2]
+  - [3, 4.5]
+`), &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMapWrapper(obj)
+
+	var limits []map[string]int
+	if err = m.Get("limits", &limits); err != nil || !reflect.DeepEqual(limits, []map[string]int{{"cpu": 1, "mem": 512}, {"cpu": 2, "mem": 1024}}) {
+		t.Errorf("unexpected limits %v, %v", limits, err)
+	}
+	var matrix [][]string
+	if err = m.Get("matrix", &matrix); err != nil || !reflect.DeepEqual(matrix, [][]string{{"a", "b"}, {"c"}}) {
+		t.Errorf("unexpected matrix %v, %v", matrix, err)
+	}
+	var groups map[string][]string
+	if err = m.Get("groups", &groups); err != nil || !reflect.DeepEqual(groups, map[string][]string{"admin": {"alice", "bob"}, "dev": {}}) {
+		t.Errorf("unexpected groups %v, %v", groups, err)
+	}
+	var grid [2][2]float64
+	if err = m.Get("grid", &grid); err != nil || grid != [2][2]float64{{1, 2}, {3, 4.5}} {
+		t.Errorf("unexpected grid %v, %v", grid, err)
+	}
+
+	m.StrictConversion = true
+	for key, target := range map[string]any{
+		"limits": &[]map[string]int8{},
+		"matrix": &[][2]string{},
+		"groups": &map[string][]int{},
+		"grid":   &[][]int{},
+	} {
+		expected := map[string]string{
+			"limits": "key limits[0].mem: 512 overflows int8",
+			"matrix": "key matrix[1] source length 1 != target length 2",
+			"groups": "key groups.admin[0]: can't convert string to int",
+			"grid":   "key grid[1][1]: 4.5 would lose its fraction as int",
+		}[key]
+		if err = m.Get(key, target); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q, got %v", key, expected, err)
+		}
+	}
+}
+
+// TestMapWrapper_RandomTrees reads random trees of mixed map and slice types
+// with every getter and only checks that nothing panics.
+func TestMapWrapper_RandomTrees(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	targets := []func() any{
+		func() any { return new(string) },
+		func() any { return new(int8) },
+		func() any { return new(float32) },
+		func() any { return new(bool) },
+		func() any { return new([]string) },
+		func() any { return new([]int) },
+		func() any { return new([2]any) },
+		func() any { return new(map[string]any) },
+		func() any { return new(map[string]string) },
+		func() any { return new(map[string][]any) },
+		func() any { return new(any) },
+		func() any { return nil },
+		func() any { return (*int)(nil) },
+	}
+
+	for i := 0; i < 500; i++ {
+		data := map[string]any{"a": randomTree(r, 3), "b": randomTree(r, 3), "1": randomTree(r, 2)}
+		m := NewMapWrapper(data)
+		m.StrictConversion = i%2 == 0
+		m.WeakCoercion = i%3 == 0
+
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Fatalf("tree %d %v: panic %v", i, data, p)
+				}
+			}()
+			for key := range data {
+				_, _ = m.GetChild(key)
+				for _, target := range targets {
+					_ = m.Get(key, target())
+				}
+				for _, path := range []string{key, key + ".a", key + "[0]", key + ".1.b", key + "[1][0]"} {
+					_, _ = m.GetPath(path)
+					_, _ = Get[[]map[string]int](m, path)
+					_ = m.GetString(path, "")
+				}
+			}
+			_ = m.Decode(&struct {
+				A string         `cfg:"a"`
+				B []int          `cfg:"b"`
+				C map[string]any `cfg:"c"`
+			}{})
+			_ = m.Decode(&struct {
+				A map[string]any `cfg:"a"`
+				B map[string]int `cfg:"b"`
+			}{})
+			_ = m.Decode(&struct {
+				A struct {
+					A map[string]string `cfg:"a"`
+					B []any             `cfg:"1"`
+				} `cfg:"a"`
+				B map[string][]int `cfg:"b"`
+			}{})
+
+			for _, path := range []string{"a.a", "a[0]", "b.1.b", "b[1][0]", "1.c", "a.true"} {
+				if err := m.SetPath(path, "v"); err == nil {
+					if value, err := m.GetPath(path); err != nil || value != "v" {
+						t.Fatalf("tree %d: %s: expected the set value, got %v, %v", i, path, value, err)
+					}
+				}
+				_, _ = m.DeletePath(path)
+				_ = m.ToMap()
+			}
+		}()
+	}
+}
+
+func randomTree(r *rand.Rand, depth int) any {
+	keys := []string{"a", "b", "c", "1"}
+	n := r.IntN(4)
+	kind := r.IntN(12)
+	if depth == 0 {
+		kind = 6 + r.IntN(6)
+	}
+	switch kind {
+	case 0, 1:
+		data := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			data[keys[r.IntN(len(keys))]] = randomTree(r, depth-1)
+		}
+		return data
+	case 2:
+		data := make(map[any]any, n)
+		for i := 0; i < n; i++ {
+			data[[]any{"a", 1, true, nil}[r.IntN(4)]] = randomTree(r, depth-1)
+		}
+		return data
+	case 3:
+		data := make([]any, n)
+		for i := range data {
+			data[i] = randomTree(r, depth-1)
+		}
+		return data
+	case 4:
+		return map[string]string{"a": "x", "b": "300"}
+	case 5:
+		return []string{"a", "1"}
+	case 6:
+		return [2]int{r.IntN(1000), -1}
+	case 7:
+		return r.Float64() * 1000
+	case 8:
+		return r.IntN(1000) - 500
+	case 9:
+		return "s"
+	case 10:
+		return r.IntN(2) == 0
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package collection
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396): maps are merged
+// recursively, null values delete keys and other values replace the target.
+func (m *MapWrapper) ApplyMergePatch(patch map[string]any) {
+	m.data = mergePatch(m.ToMap(), deepCopy(patch)).(map[string]any)
+}
+
+func mergePatch(target any, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(map[string]any)
+	if !ok {
+		targetMap = make(map[string]any, len(patchMap))
+	}
+
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+		} else {
+			targetMap[key] = mergePatch(targetMap[key], value)
+		}
+	}
+	return targetMap
+}
+
+// DiffMergePatch returns a JSON Merge Patch which turns m into target. A merge
+// patch can't set null values or change maps nested in slices, those are
+// removed or the slice is replaced.
+func (m *MapWrapper) DiffMergePatch(target *MapWrapper) map[string]any {
+	return diffMerge(m.ToMap(), target.ToMap())
+}
+
+func diffMerge(from, to map[string]any) map[string]any {
+	patch := make(map[string]any)
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, value := range to {
+		old, ok := from[key]
+		if ok && jsonEqual(old, value) {
+			continue
+		}
+		oldMap, isOldMap := old.(map[string]any)
+		newMap, isNewMap := value.(map[string]any)
+		if isOldMap && isNewMap {
+			patch[key] = diffMerge(oldMap, newMap)
+		} else {
+			patch[key] = value
+		}
+	}
+	return patch
+}
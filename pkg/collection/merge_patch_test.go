@@ -0,0 +1,76 @@
+package collection
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMapWrapper_ApplyMergePatch(t *testing.T) {
+	// the examples of RFC 7396 with a map as the target
+	testCases := []struct {
+		target   string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range testCases {
+		var target, patch, expected map[string]any
+		for i, out := range []*map[string]any{&target, &patch, &expected} {
+			if err := json.Unmarshal([]byte([]string{tc.target, tc.patch, tc.expected}[i]), out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		m := NewMapWrapper(target)
+		m.ApplyMergePatch(patch)
+		if !reflect.DeepEqual(m.ToMap(), expected) {
+			t.Errorf("%s + %s: expected %v, got %v", tc.target, tc.patch, expected, m.ToMap())
+		}
+	}
+}
+
+func TestMapWrapper_DiffMergePatch(t *testing.T) {
+	from := NewMapWrapper(map[string]any{
+		"name":   "app",
+		"port":   80,
+		"tls":    map[any]any{"cert": "a.pem", "key": "a.key"},
+		"hosts":  []any{"a", "b"},
+		"debug":  true,
+		"labels": map[string]any{"env": "prod"},
+	})
+	to := NewMapWrapper(map[string]any{
+		"name":   "app",
+		"port":   8080.0,
+		"tls":    map[string]any{"cert": "b.pem"},
+		"hosts":  []any{"a"},
+		"labels": map[string]any{"env": "prod"},
+		"extra":  map[string]any{"a": 1},
+	})
+
+	patch := from.DiffMergePatch(to)
+	expected := map[string]any{
+		"port":  8080.0,
+		"tls":   map[string]any{"cert": "b.pem", "key": nil},
+		"hosts": []any{"a"},
+		"debug": nil,
+		"extra": map[string]any{"a": 1},
+	}
+	if !reflect.DeepEqual(patch, expected) {
+		t.Errorf("expected %v, got %v", expected, patch)
+	}
+
+	from.ApplyMergePatch(patch)
+	if !jsonEqual(from.ToMap(), to.ToMap()) {
+		t.Errorf("expected %v, got %v", to.ToMap(), from.ToMap())
+	}
+}
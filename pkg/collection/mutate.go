@@ -0,0 +1,308 @@
+package collection
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Set sets the top-level key.
+func (m *MapWrapper) Set(key string, value any) {
+	if m.data == nil {
+		m.data = make(map[string]any)
+	}
+	m.data[key] = value
+}
+
+// SetPath sets the value at path, see GetPath. Missing maps are created for key
+// segments and slices for index segments, slices grow to the index with nil
+// items.
+func (m *MapWrapper) SetPath(path string, value any) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if segments[0].isIndex {
+		return &PathError{Path: path, Segment: formatPath(segments[:1]), Err: fmt.Errorf("index on a map")}
+	}
+
+	if m.data == nil {
+		m.data = make(map[string]any)
+	}
+	_, err = setIn(m.data, segments, 0, value, path)
+	return err
+}
+
+// setIn sets the value of segments[i:] in container and returns the container,
+// which is a new one if it was nil or a slice that grew.
+func setIn(container any, segments []pathSegment, i int, value any, path string) (any, error) {
+	segment := segments[i]
+	last := i == len(segments)-1
+	fail := func(err error) error {
+		return &PathError{Path: path, Segment: formatPath(segments[:i+1]), Err: err}
+	}
+
+	if container == nil {
+		if segment.isIndex {
+			container = []any{}
+		} else {
+			container = make(map[string]any)
+		}
+	}
+
+	switch c := container.(type) {
+	case map[string]any:
+		if segment.isIndex {
+			return nil, fail(fmt.Errorf("index on a map"))
+		}
+		if last {
+			c[segment.key] = value
+			return c, nil
+		}
+		child, err := setIn(c[segment.key], segments, i+1, value, path)
+		if err != nil {
+			return nil, err
+		}
+		c[segment.key] = child
+		return c, nil
+	case []any:
+		index, err := segmentIndex(segment)
+		if err != nil {
+			return nil, fail(err)
+		}
+		for len(c) <= index {
+			c = append(c, nil)
+		}
+		if last {
+			c[index] = value
+			return c, nil
+		}
+		child, err := setIn(c[index], segments, i+1, value, path)
+		if err != nil {
+			return nil, err
+		}
+		c[index] = child
+		return c, nil
+	default:
+		return setInValue(reflect.ValueOf(container), segments, i, value, path)
+	}
+}
+
+// setInValue is setIn for other maps, slices and arrays, e.g. map[any]any or
+// []string. value is converted to their item type, see convertStrict.
+func setInValue(val reflect.Value, segments []pathSegment, i int, value any, path string) (any, error) {
+	segment := segments[i]
+	fail := func(err error) error {
+		return &PathError{Path: path, Segment: formatPath(segments[:i+1]), Err: err}
+	}
+
+	var item func() reflect.Value
+	var key reflect.Value
+	switch val.Kind() {
+	case reflect.Map:
+		if segment.isIndex {
+			return nil, fail(fmt.Errorf("index on a map"))
+		}
+		var err error
+		if key, err = matchKey(val, segment.key); err != nil {
+			return nil, fail(err)
+		}
+		if val.IsNil() {
+			val = reflect.MakeMap(val.Type())
+		}
+		item = func() reflect.Value { return val.MapIndex(key) }
+	case reflect.Slice, reflect.Array:
+		index, err := segmentIndex(segment)
+		if err != nil {
+			return nil, fail(err)
+		}
+		if val.Kind() == reflect.Array {
+			if index >= val.Len() {
+				return nil, fail(fmt.Errorf("index %d out of range, length %d", index, val.Len()))
+			}
+			// an array in an interface can't be set, so it is copied
+			copied := reflect.New(val.Type()).Elem()
+			copied.Set(val)
+			val = copied
+		}
+		for val.Len() <= index {
+			val = reflect.Append(val, reflect.Zero(val.Type().Elem()))
+		}
+		item = func() reflect.Value { return val.Index(index) }
+	default:
+		return nil, fail(fmt.Errorf("parent is a %T, not a map or slice", val.Interface()))
+	}
+
+	child := value
+	if i < len(segments)-1 {
+		var current any
+		if existing := item(); existing.IsValid() {
+			current = existing.Interface()
+		}
+		var err error
+		if child, err = setIn(current, segments, i+1, value, path); err != nil {
+			return nil, err
+		}
+	}
+
+	converted := reflect.Zero(val.Type().Elem())
+	if child != nil {
+		var err error
+		if converted, err = convertStrict(reflect.ValueOf(child), val.Type().Elem()); err != nil {
+			return nil, fail(err)
+		}
+	}
+	if val.Kind() == reflect.Map {
+		val.SetMapIndex(key, converted)
+	} else {
+		item().Set(converted)
+	}
+	return val.Interface(), nil
+}
+
+// Delete deletes the top-level key, it returns false if the key doesn't exist.
+func (m *MapWrapper) Delete(key string) bool {
+	_, ok := m.data[key]
+	delete(m.data, key)
+	return ok
+}
+
+// DeletePath deletes the value at path, items after a deleted slice item move
+// up. It returns false if the path doesn't exist.
+func (m *MapWrapper) DeletePath(path string) (bool, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	var parent any = m.data
+	if len(segments) > 1 {
+		if parent, err = m.GetPath(formatPath(segments[:len(segments)-1])); err != nil {
+			return false, nil
+		}
+	}
+
+	segment := segments[len(segments)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[segment.key]; !ok || segment.isIndex {
+			return false, nil
+		}
+		delete(p, segment.key)
+		return true, nil
+	case []any:
+		index, err := segmentIndex(segment)
+		if err != nil || index >= len(p) {
+			return false, nil
+		}
+		// the slice header is held by the grandparent, so it is set again
+		return true, m.SetPath(formatPath(segments[:len(segments)-1]), append(p[:index:index], p[index+1:]...))
+	}
+
+	val := reflect.ValueOf(parent)
+	switch val.Kind() {
+	case reflect.Map:
+		key, err := matchKey(val, segment.key)
+		if err != nil || segment.isIndex || !val.MapIndex(key).IsValid() {
+			return false, nil
+		}
+		val.SetMapIndex(key, reflect.Value{})
+		return true, nil
+	case reflect.Slice:
+		index, err := segmentIndex(segment)
+		if err != nil || index >= val.Len() {
+			return false, nil
+		}
+		items := reflect.MakeSlice(val.Type(), 0, val.Len()-1)
+		items = reflect.AppendSlice(reflect.AppendSlice(items, val.Slice(0, index)), val.Slice(index+1, val.Len()))
+		return true, m.SetPath(formatPath(segments[:len(segments)-1]), items.Interface())
+	case reflect.Array:
+		return false, &PathError{Path: path, Segment: path, Err: fmt.Errorf("can't delete from a %T", parent)}
+	default:
+		return false, &PathError{Path: path, Segment: path, Err: fmt.Errorf("parent is a %T, not a map or slice", parent)}
+	}
+}
+
+func segmentIndex(segment pathSegment) (int, error) {
+	if segment.isIndex {
+		return segment.index, nil
+	}
+	index, err := strconv.Atoi(segment.key)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("key %s on a slice", segment.key)
+	}
+	return index, nil
+}
+
+// ToMap returns a deep copy of the wrapped map, other maps are converted to
+// map[string]any and slices and arrays to []any.
+func (m *MapWrapper) ToMap() map[string]any {
+	if m.data == nil {
+		return map[string]any{}
+	}
+	return deepCopy(m.data).(map[string]any)
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case map[any]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[fmt.Sprint(key)] = deepCopy(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	case []byte:
+		return slices.Clone(v)
+	}
+
+	// other maps, slices and arrays, e.g. map[string]string or []int
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Map:
+		copied := make(map[string]any, val.Len())
+		for iter := val.MapRange(); iter.Next(); {
+			copied[fmt.Sprint(iter.Key().Interface())] = deepCopy(iter.Value().Interface())
+		}
+		return copied
+	case reflect.Slice, reflect.Array:
+		copied := make([]any, val.Len())
+		for i := range copied {
+			copied[i] = deepCopy(val.Index(i).Interface())
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+func (m *MapWrapper) MarshalYAML() (any, error) {
+	return m.ToMap(), nil
+}
+
+func (m *MapWrapper) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.ToMap())
+}
+
+func (m *MapWrapper) ToYAML() ([]byte, error) {
+	return yaml.Marshal(m.ToMap())
+}
+
+func (m *MapWrapper) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(m.ToMap(), "", "  ")
+}
@@ -0,0 +1,238 @@
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMapWrapper_SetPath(t *testing.T) {
+	m := NewMapWrapper(nil)
+	m.Set("name", "app")
+
+	testCases := []struct {
+		path  string
+		value any
+	}{
+		{"server.tls.cert", "a.pem"},
+		{"server.backends[2].host", "c"},
+		{"server.backends[0].host", "a"},
+		{`server.example\.com.port`, 443},
+		{"matrix[1][1]", 4},
+		{"server.backends.2.port", 8080},
+	}
+	for _, tc := range testCases {
+		if err := m.SetPath(tc.path, tc.value); err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+	}
+
+	expected := map[string]any{
+		"name": "app",
+		"server": map[string]any{
+			"tls": map[string]any{"cert": "a.pem"},
+			"backends": []any{
+				map[string]any{"host": "a"},
+				nil,
+				map[string]any{"host": "c", "port": 8080},
+			},
+			"example.com": map[string]any{"port": 443},
+		},
+		"matrix": []any{nil, []any{nil, 4}},
+	}
+	if !reflect.DeepEqual(m.ToMap(), expected) {
+		t.Errorf("expected %v, got %v", expected, m.ToMap())
+	}
+
+	for path, segment := range map[string]string{
+		"name.first":           "name.first",
+		"server.tls[0]":        "server.tls[0]",
+		"server.backends.x":    "server.backends.x",
+		"[0]":                  "[0]",
+		"server.tls.cert.path": "server.tls.cert.path",
+	} {
+		var pathErr *PathError
+		if err := m.SetPath(path, 1); !errors.As(err, &pathErr) || pathErr.Segment != segment {
+			t.Errorf("%s: expected error at %s, got %v", path, segment, err)
+		}
+	}
+}
+
+func TestMapWrapper_DeletePath(t *testing.T) {
+	m := NewMapWrapper(map[string]any{
+		"name": "app",
+		"server": map[string]any{
+			"tls":      map[string]any{"cert": "a.pem", "key": "a.key"},
+			"backends": []any{"a", "b", "c"},
+		},
+		"yaml": map[any]any{"key": 1},
+	})
+
+	if !m.Delete("name") || m.Delete("name") {
+		t.Error("expected name to be deleted once")
+	}
+
+	for path, deleted := range map[string]bool{
+		"server.tls.key":       true,
+		"server.tls.missing":   false,
+		"server.backends[1]":   true,
+		"server.backends[5]":   false,
+		"server.missing.x":     false,
+		"yaml.key":             true,
+		"server.tls.cert[0]":   false,
+		"server.backends.name": false,
+	} {
+		ok, err := m.DeletePath(path)
+		if err != nil && path != "server.tls.cert[0]" {
+			t.Errorf("%s: %v", path, err)
+		}
+		if ok != deleted {
+			t.Errorf("%s: expected deleted %v, got %v", path, deleted, ok)
+		}
+	}
+
+	expected := map[string]any{
+		"server": map[string]any{
+			"tls":      map[string]any{"cert": "a.pem"},
+			"backends": []any{"a", "c"},
+		},
+		"yaml": map[string]any{},
+	}
+	if !reflect.DeepEqual(m.ToMap(), expected) {
+		t.Errorf("expected %v, got %v", expected, m.ToMap())
+	}
+}
+
+func TestMapWrapper_MutateAnyKeys(t *testing.T) {
+	m := NewMapWrapper(map[string]any{"v2": map[any]any{1: "one", true: map[any]any{"x": 0}}})
+
+	if err := m.SetPath("v2.1", "uno"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetPath("v2.true.x", 1); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[any]any{1: "uno", true: map[any]any{"x": 1}}
+	if value, _ := m.GetPath("v2"); !reflect.DeepEqual(value, expected) {
+		t.Errorf("expected %v, got %v", expected, value)
+	}
+
+	if ok, err := m.DeletePath("v2.1"); err != nil || !ok {
+		t.Errorf("expected v2.1 to be deleted, got %v, %v", ok, err)
+	}
+	if m.HasPath("v2.1") {
+		t.Error("expected v2.1 to be gone")
+	}
+}
+
+func TestMapWrapper_MutateTypedContainers(t *testing.T) {
+	m := NewMapWrapper(map[string]any{
+		"labels": map[string]string{"a": "x", "b": "y"},
+		"ports":  []int{80, 443},
+		"pair":   [2]string{"a", "b"},
+		"nested": map[string][]string{"hosts": {"h1"}},
+	})
+
+	for path, value := range map[string]any{
+		"labels.c":       "z",
+		"ports[3]":       8443,
+		"pair[1]":        "c",
+		"nested.hosts.1": "h2",
+		"nested.more":    []string{"m"},
+	} {
+		if err := m.SetPath(path, value); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	for path, deleted := range map[string]bool{
+		"labels.a":       true,
+		"labels.missing": false,
+		"ports[0]":       true,
+		"ports[9]":       false,
+		"nested.hosts.0": true,
+	} {
+		if ok, err := m.DeletePath(path); err != nil || ok != deleted {
+			t.Errorf("%s: expected deleted %v, got %v, %v", path, deleted, ok, err)
+		}
+	}
+
+	expected := map[string]any{
+		"labels": map[string]string{"b": "y", "c": "z"},
+		"ports":  []int{443, 0, 8443},
+		"pair":   [2]string{"a", "c"},
+		"nested": map[string][]string{"hosts": {"h2"}, "more": {"m"}},
+	}
+	if !reflect.DeepEqual(m.data, expected) {
+		t.Errorf("expected %v, got %v", expected, m.data)
+	}
+
+	if err := m.SetPath("labels.d", 1); err == nil {
+		t.Error("expected error for an int in a map[string]string")
+	}
+	if err := m.SetPath("pair[2]", "d"); err == nil {
+		t.Error("expected error for an index past an array")
+	}
+	if _, err := m.DeletePath("pair[0]"); err == nil {
+		t.Error("expected error for deleting from an array")
+	}
+
+	normalized := map[string]any{
+		"labels": map[string]any{"b": "y", "c": "z"},
+		"ports":  []any{443, 0, 8443},
+		"pair":   []any{"a", "c"},
+		"nested": map[string]any{"hosts": []any{"h2"}, "more": []any{"m"}},
+	}
+	if !reflect.DeepEqual(m.ToMap(), normalized) {
+		t.Errorf("expected %v, got %v", normalized, m.ToMap())
+	}
+	if err := m.ApplyPatch(Patch{{Op: "add", Path: "/labels/d", Value: "w"}, {Op: "remove", Path: "/ports/0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := m.GetPath("labels.d"); value != "w" {
+		t.Errorf("expected patched label, got %v", value)
+	}
+}
+
+func TestMapWrapper_Marshal(t *testing.T) {
+	m := NewMapWrapper(map[string]any{
+		"name": "app",
+		"yaml": map[any]any{"port": 80},
+		"list": []any{1, "a"},
+	})
+
+	copied := m.ToMap()
+	copied["list"].([]any)[0] = 2
+	if value, _ := m.GetPath("list[0]"); value != 1 {
+		t.Error("expected ToMap to return a copy")
+	}
+
+	data, err := m.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON map[string]any
+	if err = json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if fromJSON["yaml"].(map[string]any)["port"] != float64(80) {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	data, err = yaml.Marshal(struct {
+		Cfg *MapWrapper `yaml:"cfg"`
+	}{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "cfg:\n    list:\n        - 1\n        - a\n    name: app\n    yaml:\n        port: 80\n"
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+
+	if data, err = json.Marshal(map[string]any{"cfg": m}); err != nil || string(data) != `{"cfg":{"list":[1,"a"],"name":"app","yaml":{"port":80}}}` {
+		t.Errorf("unexpected json %s, %v", data, err)
+	}
+}
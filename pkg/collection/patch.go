@@ -0,0 +1,390 @@
+package collection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrPatchTestFailed = errors.New("test failed")
+
+// PatchOp is an operation of a JSON Patch (RFC 6902). Path and From are JSON
+// Pointers, see GetPointer.
+type PatchOp struct {
+	Op    string `json:"op" yaml:"op"`
+	Path  string `json:"path" yaml:"path"`
+	From  string `json:"from,omitempty" yaml:"from,omitempty"`
+	Value any    `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Patch is a JSON Patch, its operations are applied in order.
+type Patch []PatchOp
+
+// MarshalJSON writes value only for add, replace and test so a null value is
+// kept, and from only for move and copy.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(op.members())
+}
+
+// MarshalYAML writes the same members as MarshalJSON.
+func (op PatchOp) MarshalYAML() (any, error) {
+	return op.members(), nil
+}
+
+type patchOpMembers struct {
+	Op    string  `json:"op" yaml:"op"`
+	From  *string `json:"from,omitempty" yaml:"from,omitempty"`
+	Path  string  `json:"path" yaml:"path"`
+	Value *any    `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+func (op PatchOp) members() patchOpMembers {
+	out := patchOpMembers{Op: op.Op, Path: op.Path}
+	switch op.Op {
+	case "add", "replace", "test":
+		out.Value = &op.Value
+	case "move", "copy":
+		out.From = &op.From
+	}
+	return out
+}
+
+// ParsePatch parses a JSON Patch written as JSON or YAML and checks that every
+// operation has the members it needs.
+func ParsePatch(data []byte) (Patch, error) {
+	ops, err := unmarshalPatchJSON(data)
+	if err != nil {
+		// YAML rejects some JSON escapes, e.g. \/, so it is only the fallback
+		if err = yaml.Unmarshal(data, &ops); err != nil {
+			return nil, fmt.Errorf("invalid patch: %w", err)
+		}
+	}
+
+	patch := make(Patch, 0, len(ops))
+	for i, op := range ops {
+		var patchOp PatchOp
+		var ok bool
+		if patchOp.Op, ok = op["op"].(string); !ok {
+			return nil, fmt.Errorf("patch op %d: missing op", i)
+		}
+		if patchOp.Path, ok = op["path"].(string); !ok {
+			return nil, fmt.Errorf("patch op %d: missing path", i)
+		}
+		switch patchOp.Op {
+		case "add", "replace", "test":
+			if patchOp.Value, ok = op["value"]; !ok {
+				return nil, fmt.Errorf("patch op %d %s: missing value", i, patchOp.Op)
+			}
+		case "move", "copy":
+			if patchOp.From, ok = op["from"].(string); !ok {
+				return nil, fmt.Errorf("patch op %d %s: missing from", i, patchOp.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("patch op %d: unknown op %s", i, patchOp.Op)
+		}
+		patch = append(patch, patchOp)
+	}
+	return patch, nil
+}
+
+// unmarshalPatchJSON decodes the operations of a JSON Patch, numbers become
+// ints if they are integers and float64 otherwise like YAML decodes them.
+func unmarshalPatchJSON(data []byte) ([]map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var ops []map[string]any
+	if err := decoder.Decode(&ops); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("data after the patch")
+	}
+	for _, op := range ops {
+		for key, value := range op {
+			op[key] = jsonNumbers(value)
+		}
+	}
+	return ops, nil
+}
+
+func jsonNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 0); err == nil {
+			return int(n)
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	}
+	return value
+}
+
+// ApplyPatch applies the JSON Patch. If an operation fails, m is unchanged and
+// the error names the operation, a failed test wraps ErrPatchTestFailed.
+func (m *MapWrapper) ApplyPatch(patch Patch) error {
+	doc := &patchDoc{root: m.ToMap()}
+	for i, op := range patch {
+		if err := doc.apply(op); err != nil {
+			return fmt.Errorf("patch op %d %s %s: %w", i, op.Op, op.Path, err)
+		}
+	}
+	m.data = doc.root.(map[string]any)
+	return nil
+}
+
+// patchDoc is a copy of the map tree which patches are applied to, only
+// map[string]any and []any containers are modified.
+type patchDoc struct {
+	root any
+}
+
+func (d *patchDoc) apply(op PatchOp) error {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		return d.set(tokens, deepCopy(op.Value), true)
+	case "remove":
+		_, err = d.remove(tokens)
+		return err
+	case "replace":
+		if _, err = pointerGet(d.root, tokens, op.Path); err != nil {
+			return err
+		}
+		return d.set(tokens, deepCopy(op.Value), false)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "copy" {
+			value, err := pointerGet(d.root, from, op.From)
+			if err != nil {
+				return err
+			}
+			return d.set(tokens, deepCopy(value), true)
+		}
+		if len(from) < len(tokens) && slices.Equal(from, tokens[:len(from)]) {
+			return fmt.Errorf("can't move %s into itself", op.From)
+		}
+		value, err := d.remove(from)
+		if err != nil {
+			return err
+		}
+		return d.set(tokens, value, true)
+	case "test":
+		value, err := pointerGet(d.root, tokens, op.Path)
+		if err != nil {
+			return err
+		}
+		if !jsonEqual(value, op.Value) {
+			return fmt.Errorf("%v != %v: %w", value, op.Value, ErrPatchTestFailed)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown op %s", op.Op)
+	}
+}
+
+// set sets the value at tokens, if insert is set it is inserted into a slice
+// or added to a map, otherwise the existing value is replaced.
+func (d *patchDoc) set(tokens []string, value any, insert bool) error {
+	ptr := formatPointer(tokens)
+	if len(tokens) == 0 {
+		if _, ok := value.(map[string]any); !ok {
+			return fmt.Errorf("root must be a map, not a %T", value)
+		}
+		d.root = value
+		return nil
+	}
+
+	parent, err := pointerGet(d.root, tokens[:len(tokens)-1], ptr)
+	if err != nil {
+		return err
+	}
+	token := tokens[len(tokens)-1]
+	fail := func(err error) error {
+		return &PathError{Path: ptr, Segment: ptr, Err: err}
+	}
+
+	switch p := parent.(type) {
+	case map[string]any:
+		p[token] = value
+		return nil
+	case []any:
+		index, err := arrayIndex(token, len(p), insert)
+		if err != nil {
+			return fail(err)
+		}
+		if !insert {
+			p[index] = value
+			return nil
+		}
+		// the slice header is held by the grandparent, so it is set again
+		return d.set(tokens[:len(tokens)-1], slices.Insert(p[:len(p):len(p)], index, value), false)
+	default:
+		return fail(fmt.Errorf("parent is a %T, not a map[string]any or []any", parent))
+	}
+}
+
+// remove removes the value at tokens and returns it.
+func (d *patchDoc) remove(tokens []string) (any, error) {
+	ptr := formatPointer(tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("can't remove the root")
+	}
+
+	value, err := pointerGet(d.root, tokens, ptr)
+	if err != nil {
+		return nil, err
+	}
+	parent, _ := pointerGet(d.root, tokens[:len(tokens)-1], ptr)
+	token := tokens[len(tokens)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		delete(p, token)
+		return value, nil
+	case []any:
+		index, _ := arrayIndex(token, len(p), false)
+		return value, d.set(tokens[:len(tokens)-1], slices.Delete(slices.Clone(p), index, index+1), false)
+	default:
+		return nil, &PathError{Path: ptr, Segment: ptr, Err: fmt.Errorf("parent is a %T, not a map[string]any or []any", parent)}
+	}
+}
+
+// DiffPatch returns a JSON Patch which turns m into target. Maps and slices
+// are compared item by item, other values are replaced.
+func (m *MapWrapper) DiffPatch(target *MapWrapper) Patch {
+	patch := Patch{}
+	diffValues(&patch, nil, m.ToMap(), target.ToMap())
+	return patch
+}
+
+func diffValues(patch *Patch, tokens []string, from, to any) {
+	if jsonEqual(from, to) {
+		return
+	}
+	child := func(token string) []string {
+		return append(tokens[:len(tokens):len(tokens)], token)
+	}
+
+	switch f := from.(type) {
+	case map[string]any:
+		t, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, key := range slices.Sorted(maps.Keys(f)) {
+			if _, ok := t[key]; !ok {
+				*patch = append(*patch, PatchOp{Op: "remove", Path: formatPointer(child(key))})
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(t)) {
+			if value, ok := f[key]; ok {
+				diffValues(patch, child(key), value, t[key])
+			} else {
+				*patch = append(*patch, PatchOp{Op: "add", Path: formatPointer(child(key)), Value: t[key]})
+			}
+		}
+		return
+	case []any:
+		t, ok := to.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < min(len(f), len(t)); i++ {
+			diffValues(patch, child(fmt.Sprint(i)), f[i], t[i])
+		}
+		for i := len(f) - 1; i >= len(t); i-- {
+			*patch = append(*patch, PatchOp{Op: "remove", Path: formatPointer(child(fmt.Sprint(i)))})
+		}
+		for i := len(f); i < len(t); i++ {
+			*patch = append(*patch, PatchOp{Op: "add", Path: formatPointer(child("-")), Value: t[i]})
+		}
+		return
+	}
+	*patch = append(*patch, PatchOp{Op: "replace", Path: formatPointer(tokens), Value: to})
+}
+
+// jsonEqual compares like JSON values, numbers are equal if their values are
+// equal regardless of their type.
+func jsonEqual(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+
+	switch {
+	case isNumber(va.Kind()) && isNumber(vb.Kind()):
+		return numberEqual(va, vb)
+	case va.Kind() == reflect.Map && vb.Kind() == reflect.Map:
+		if va.Len() != vb.Len() {
+			return false
+		}
+		for iter := va.MapRange(); iter.Next(); {
+			other, err := mapIndex(vb, fmt.Sprint(iter.Key().Interface()))
+			if err != nil || !other.IsValid() || !jsonEqual(iter.Value().Interface(), other.Interface()) {
+				return false
+			}
+		}
+		return true
+	case (va.Kind() == reflect.Slice || va.Kind() == reflect.Array) && (vb.Kind() == reflect.Slice || vb.Kind() == reflect.Array):
+		if va.Len() != vb.Len() {
+			return false
+		}
+		for i := 0; i < va.Len(); i++ {
+			if !jsonEqual(va.Index(i).Interface(), vb.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func numberEqual(a, b reflect.Value) bool {
+	if isFloat(a.Kind()) || isFloat(b.Kind()) {
+		return numberFloat(a) == numberFloat(b)
+	}
+	for _, t := range []reflect.Type{reflect.TypeFor[int64](), reflect.TypeFor[uint64]()} {
+		x, errA := convertNumber(a, t)
+		y, errB := convertNumber(b, t)
+		if errA == nil && errB == nil {
+			return x.Equal(y)
+		}
+	}
+	return false
+}
+
+func numberFloat(val reflect.Value) float64 {
+	switch {
+	case isInt(val.Kind()):
+		return float64(val.Int())
+	case isUint(val.Kind()):
+		return float64(val.Uint())
+	default:
+		return val.Float()
+	}
+}
@@ -0,0 +1,205 @@
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMapWrapper_ApplyPatch(t *testing.T) {
+	// examples of RFC 6902 appendix A
+	testCases := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"foo":{"bar":[1]}}`, `[{"op":"copy","from":"/foo/bar","path":"/foo/baz"},{"op":"add","path":"/foo/baz/0","value":0}]`, `{"foo":{"bar":[1],"baz":[0,1]}}`},
+		{`{"a":{"b":[1,2]}}`, `[{"op":"add","path":"/a/b/2","value":3},{"op":"remove","path":"/a/b/0"}]`, `{"a":{"b":[2,3]}}`},
+		{`{"a":1}`, `[{"op":"replace","path":"","value":{"b":2}}]`, `{"b":2}`},
+	}
+	for _, tc := range testCases {
+		var doc, expected map[string]any
+		if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+			t.Fatal(err)
+		}
+		patch, err := ParsePatch([]byte(tc.patch))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.patch, err)
+		}
+
+		m := NewMapWrapper(doc)
+		if err = m.ApplyPatch(patch); err != nil {
+			t.Errorf("%s: %v", tc.patch, err)
+		} else if !jsonEqual(m.ToMap(), expected) {
+			t.Errorf("%s: expected %v, got %v", tc.patch, expected, m.ToMap())
+		}
+	}
+}
+
+func TestMapWrapper_ApplyPatchErrors(t *testing.T) {
+	testCases := map[string]string{
+		`[{"op":"add","path":"/baz/bat","value":"qux"}]`:                  "segment /baz: not found",
+		`[{"op":"add","path":"/list/5","value":1}]`:                       "out of range",
+		`[{"op":"remove","path":"/missing"}]`:                             "not found",
+		`[{"op":"replace","path":"/missing","value":1}]`:                  "not found",
+		`[{"op":"test","path":"/name","value":"other"}]`:                  "test failed",
+		`[{"op":"move","from":"/list","path":"/list/0"}]`:                 "into itself",
+		`[{"op":"add","path":"/name/x","value":1}]`:                       "not a map[string]any or []any",
+		`[{"op":"add","path":"","value":[1]}]`:                            "root must be a map",
+		`[{"op":"remove","path":"/name"},{"op":"remove","path":"/name"}]`: "patch op 1 remove /name",
+	}
+	for patchStr, expected := range testCases {
+		m := NewMapWrapper(map[string]any{"name": "app", "list": []any{1, 2}})
+		before := m.ToMap()
+		patch, err := ParsePatch([]byte(patchStr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = m.ApplyPatch(patch); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", patchStr, expected, err)
+		}
+		if !reflect.DeepEqual(m.ToMap(), before) {
+			t.Errorf("%s: expected no change on error, got %v", patchStr, m.ToMap())
+		}
+	}
+
+	m := NewMapWrapper(map[string]any{"n": 1})
+	if err := m.ApplyPatch(Patch{{Op: "test", Path: "/n", Value: 2.0}}); !errors.Is(err, ErrPatchTestFailed) {
+		t.Errorf("expected ErrPatchTestFailed, got %v", err)
+	}
+	if err := m.ApplyPatch(Patch{{Op: "test", Path: "/n", Value: 1.0}}); err != nil {
+		t.Errorf("expected 1 == 1.0, got %v", err)
+	}
+
+	for patchStr, expected := range map[string]string{
+		`[{"path":"/a"}]`:              "missing op",
+		`[{"op":"add","value":1}]`:     "missing path",
+		`[{"op":"add","path":"/a"}]`:   "missing value",
+		`[{"op":"copy","path":"/a"}]`:  "missing from",
+		`[{"op":"merge","path":"/a"}]`: "unknown op merge",
+		`{"op":"add"}`:                 "invalid patch",
+	} {
+		if _, err := ParsePatch([]byte(patchStr)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", patchStr, expected, err)
+		}
+	}
+}
+
+func TestParsePatch_YAML(t *testing.T) {
+	patch, err := ParsePatch([]byte(`
+- op: replace
+  path: /server/port
+  value: 8443
+- op: add
+  path: /server/tls
+  value:
+    cert: a.pem
+- op: remove
+  path: /debug
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]any
+	if err = yaml.Unmarshal([]byte("server:\n  port: 80\ndebug: true\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMapWrapper(doc)
+	if err = m.ApplyPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"server": map[string]any{"port": 8443, "tls": map[string]any{"cert": "a.pem"}}}
+	if !reflect.DeepEqual(m.ToMap(), expected) {
+		t.Errorf("expected %v, got %v", expected, m.ToMap())
+	}
+}
+
+func TestParsePatch_JSONEscapes(t *testing.T) {
+	patch, err := ParsePatch([]byte(`[{"op":"add","path":"/u","value":"http:\/\/x\u00e9"},
+		{"op":"add","path":"/n","value":[1, 2.5, 12345678901234567890]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch[0].Value != "http://xé" {
+		t.Errorf("unexpected value %q", patch[0].Value)
+	}
+	if expected := []any{1, 2.5, 12345678901234567890.0}; !reflect.DeepEqual(patch[1].Value, expected) {
+		t.Errorf("expected %v, got %#v", expected, patch[1].Value)
+	}
+}
+
+func TestMapWrapper_DiffPatch(t *testing.T) {
+	from := NewMapWrapper(map[string]any{
+		"name":  "app",
+		"port":  80,
+		"tls":   map[any]any{"cert": "a.pem", "key": "a.key"},
+		"hosts": []any{"a", "b", "c"},
+		"a/b":   1,
+		"list":  []any{1},
+	})
+	to := NewMapWrapper(map[string]any{
+		"name":  "app",
+		"port":  80.0,
+		"tls":   map[string]any{"cert": "b.pem"},
+		"hosts": []any{"a", "x"},
+		"a/b":   nil,
+		"list":  []any{1, 2, 3},
+		"new":   map[string]any{"x": 1},
+	})
+
+	patch := from.DiffPatch(to)
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"replace","path":"/a~1b","value":null},` +
+		`{"op":"replace","path":"/hosts/1","value":"x"},{"op":"remove","path":"/hosts/2"},` +
+		`{"op":"add","path":"/list/-","value":2},{"op":"add","path":"/list/-","value":3},` +
+		`{"op":"add","path":"/new","value":{"x":1}},` +
+		`{"op":"remove","path":"/tls/key"},{"op":"replace","path":"/tls/cert","value":"b.pem"}]`
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+
+	if err = from.ApplyPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(from.ToMap(), to.ToMap()) {
+		t.Errorf("expected %v, got %v", to.ToMap(), from.ToMap())
+	}
+	if patch = from.DiffPatch(to); len(patch) != 0 {
+		t.Errorf("expected empty patch, got %v", patch)
+	}
+
+	data, err = yaml.Marshal(Patch{{Op: "move", From: "/a", Path: "/b"}, {Op: "add", Path: "/c", Value: nil}})
+	if err != nil || string(data) != "- op: move\n  from: /a\n  path: /b\n- op: add\n  path: /c\n  value: null\n" {
+		t.Errorf("unexpected yaml %s, %v", data, err)
+	}
+}
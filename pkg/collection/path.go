@@ -0,0 +1,225 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrPathNotFound = errors.New("not found")
+
+// PathError is a failed path lookup, Segment is the path up to and including
+// the segment that failed.
+type PathError struct {
+	Path    string
+	Segment string
+	Err     error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("path %s: segment %s: %v", e.Path, e.Segment, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return "[" + strconv.Itoa(s.index) + "]"
+	}
+	return escapeKey(s.key)
+}
+
+// parsePath splits a path like server.tls.certs[1].path into its segments. A
+// backslash escapes the next character of a key, e.g. a\.b is the key a.b.
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var segments []pathSegment
+	var key strings.Builder
+	inKey := false
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("path %s: trailing escape", path)
+			}
+			i++
+			key.WriteByte(path[i])
+			inKey = true
+		case '.':
+			if !inKey && (i == 0 || path[i-1] != ']') {
+				return nil, fmt.Errorf("path %s: empty key at offset %d", path, i)
+			}
+			if inKey {
+				segments = append(segments, pathSegment{key: key.String()})
+				key.Reset()
+				inKey = false
+			}
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("path %s: empty key at offset %d", path, i+1)
+			}
+		case '[':
+			if inKey {
+				segments = append(segments, pathSegment{key: key.String()})
+				key.Reset()
+				inKey = false
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %s: unclosed [ at offset %d", path, i)
+			}
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path %s: invalid index %s at offset %d", path, path[i+1:i+end], i)
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+			i += end
+			if i+1 < len(path) && path[i+1] != '.' && path[i+1] != '[' {
+				return nil, fmt.Errorf("path %s: unexpected %c at offset %d", path, path[i+1], i+1)
+			}
+		case ']':
+			return nil, fmt.Errorf("path %s: unexpected ] at offset %d", path, i)
+		default:
+			key.WriteByte(c)
+			inKey = true
+		}
+	}
+	if inKey {
+		segments = append(segments, pathSegment{key: key.String()})
+	}
+	return segments, nil
+}
+
+// formatPath joins the segments to a path accepted by parsePath.
+func formatPath(segments []pathSegment) string {
+	var sb strings.Builder
+	for i, segment := range segments {
+		if i > 0 && !segment.isIndex {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(segment.String())
+	}
+	return sb.String()
+}
+
+func escapeKey(key string) string {
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(key[i])
+	}
+	return sb.String()
+}
+
+// GetPath returns the value at the path, e.g. server.tls.certs[1].path. Numeric
+// keys also index slices, so certs.1 equals certs[1]. Keys containing . [ ] or
+// \ are escaped with \. Errors are a *PathError naming the failed segment.
+func (m *MapWrapper) GetPath(path string) (any, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var current any = m.data
+	for i, segment := range segments {
+		if current, err = childValue(current, segment); err != nil {
+			return nil, &PathError{Path: path, Segment: formatPath(segments[:i+1]), Err: err}
+		}
+	}
+	return current, nil
+}
+
+// HasPath returns true if the path exists.
+func (m *MapWrapper) HasPath(path string) bool {
+	_, err := m.GetPath(path)
+	return err == nil
+}
+
+// childValue returns the value of the segment in a map with string keys or a
+// slice or array.
+func childValue(value any, segment pathSegment) (any, error) {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil, fmt.Errorf("parent is nil")
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Map:
+		if segment.isIndex {
+			return nil, fmt.Errorf("index on a map")
+		}
+		child, err := mapIndex(val, segment.key)
+		if err != nil {
+			return nil, err
+		}
+		if !child.IsValid() {
+			return nil, ErrPathNotFound
+		}
+		return child.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		index := segment.index
+		if !segment.isIndex {
+			var err error
+			if index, err = strconv.Atoi(segment.key); err != nil || index < 0 {
+				return nil, fmt.Errorf("key %s on a %s", segment.key, val.Kind())
+			}
+		}
+		if index >= val.Len() {
+			return nil, fmt.Errorf("index %d out of range, length %d: %w", index, val.Len(), ErrPathNotFound)
+		}
+		return val.Index(index).Interface(), nil
+	case reflect.Invalid:
+		return nil, fmt.Errorf("parent is nil")
+	default:
+		return nil, fmt.Errorf("parent is a %T, not a map or slice", value)
+	}
+}
+
+// mapIndex returns the value of key in a map with string or any keys, keys of
+// other types in a map[any]any match by their formatted value.
+func mapIndex(val reflect.Value, key string) (reflect.Value, error) {
+	mapKey, err := matchKey(val, key)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return val.MapIndex(mapKey), nil
+}
+
+// matchKey returns the existing key of the map which matches key like mapIndex,
+// or key converted to the key type if there is none.
+func matchKey(val reflect.Value, key string) (reflect.Value, error) {
+	switch val.Type().Key().Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(val.Type().Key()), nil
+	case reflect.Interface:
+		if val.MapIndex(reflect.ValueOf(key)).IsValid() {
+			return reflect.ValueOf(key), nil
+		}
+		for iter := val.MapRange(); iter.Next(); {
+			if fmt.Sprint(iter.Key().Interface()) == key {
+				return iter.Key(), nil
+			}
+		}
+		return reflect.ValueOf(key), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("map key type %s is not string", val.Type().Key())
+	}
+}
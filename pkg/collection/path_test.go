@@ -0,0 +1,114 @@
+package collection
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testPathYAML = `
+server:
+  tls:
+    certs:
+      - path: /etc/a.pem
+      - path: /etc/b.pem
+  "example.com":
+    port: 443
+  "a[0]": bracket
+  "back\\slash": escaped
+matrix:
+  - [1, 2]
+  - [3, 4]
+empty:
+`
+
+func newTestPathWrapper(t *testing.T) *MapWrapper {
+	var obj map[string]any
+	if err := yaml.Unmarshal([]byte(testPathYAML), &obj); err != nil {
+		t.Fatal(err)
+	}
+	return NewMapWrapper(obj)
+}
+
+func TestMapWrapper_GetPath(t *testing.T) {
+	m := newTestPathWrapper(t)
+
+	testCases := map[string]any{
+		"server.tls.certs[1].path": "/etc/b.pem",
+		"server.tls.certs.0.path":  "/etc/a.pem",
+		`server.example\.com.port`: 443,
+		`server.a\[0\]`:            "bracket",
+		`server.back\\slash`:       "escaped",
+		"matrix[1][0]":             3,
+		"matrix.0[1]":              2,
+		"empty":                    nil,
+		"server.tls.certs[1]":      map[string]any{"path": "/etc/b.pem"},
+	}
+	for path, expected := range testCases {
+		value, err := m.GetPath(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("%s: expected %v, got %v", path, expected, value)
+		}
+		if !m.HasPath(path) {
+			t.Errorf("%s: expected HasPath", path)
+		}
+	}
+}
+
+func TestMapWrapper_GetPathErrors(t *testing.T) {
+	m := newTestPathWrapper(t)
+
+	testCases := map[string]string{
+		"server.tls.certs[2].path":   "server.tls.certs[2]",
+		"server.tls.missing.path":    "server.tls.missing",
+		"server.tls.certs.x":         "server.tls.certs.x",
+		"server.tls[0]":              "server.tls[0]",
+		"server.tls.certs[0].path.x": "server.tls.certs[0].path.x",
+		"empty.x":                    "empty.x",
+		`server.example\.com.host`:   `server.example\.com.host`,
+	}
+	for path, segment := range testCases {
+		_, err := m.GetPath(path)
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%s: expected PathError, got %v", path, err)
+			continue
+		}
+		if pathErr.Segment != segment || pathErr.Path != path {
+			t.Errorf("%s: expected segment %s, got %s", path, segment, pathErr.Segment)
+		}
+		if m.HasPath(path) {
+			t.Errorf("%s: expected !HasPath", path)
+		}
+	}
+
+	if _, err := m.GetPath("server.tls.missing"); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("expected ErrPathNotFound, got %v", err)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	segments, err := parsePath(`a\.b[2].c\\[0][1]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pathSegment{{key: "a.b"}, {index: 2, isIndex: true}, {key: `c\`}, {index: 0, isIndex: true}, {index: 1, isIndex: true}}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("expected %v, got %v", expected, segments)
+	}
+	if formatPath(segments) != `a\.b[2].c\\[0][1]` {
+		t.Errorf("unexpected format %s", formatPath(segments))
+	}
+
+	for _, path := range []string{"", ".a", "a.", "a..b", "a[", "a[x]", "a[-1]", "a]", "a[0]b", `a\`} {
+		if _, err = parsePath(path); err == nil {
+			t.Errorf("expected error for %q", path)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package collection
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// parsePointer splits a JSON Pointer (RFC 6901) like /server/certs/0 into its
+// unescaped tokens, the empty pointer is the whole document.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("pointer %s doesn't start with /", ptr)
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("pointer %s: invalid escape in %s", ptr, token)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// formatPointer joins the tokens to a JSON Pointer.
+func formatPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(escapeToken(token))
+	}
+	return sb.String()
+}
+
+func escapeToken(token string) string {
+	return pointerEscaper.Replace(token)
+}
+
+// GetPointer returns the value at the JSON Pointer, e.g. /server/certs/1/path.
+// Errors are a *PathError naming the failed token.
+func (m *MapWrapper) GetPointer(ptr string) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return pointerGet(m.data, tokens, ptr)
+}
+
+func pointerGet(value any, tokens []string, ptr string) (any, error) {
+	for i, token := range tokens {
+		child, err := pointerChild(value, token)
+		if err != nil {
+			return nil, &PathError{Path: ptr, Segment: formatPointer(tokens[:i+1]), Err: err}
+		}
+		value = child
+	}
+	return value, nil
+}
+
+func pointerChild(value any, token string) (any, error) {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Map:
+		child, err := mapIndex(val, token)
+		if err != nil {
+			return nil, err
+		}
+		if !child.IsValid() {
+			return nil, ErrPathNotFound
+		}
+		return child.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		index, err := arrayIndex(token, val.Len(), false)
+		if err != nil {
+			return nil, err
+		}
+		return val.Index(index).Interface(), nil
+	case reflect.Invalid:
+		return nil, fmt.Errorf("parent is nil")
+	default:
+		return nil, fmt.Errorf("parent is a %T, not a map or slice", value)
+	}
+}
+
+// arrayIndex parses an array index token, - is the index after the last item
+// and only valid if end is set.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" {
+		if !end {
+			return 0, fmt.Errorf("index - past the end: %w", ErrPathNotFound)
+		}
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	if index > length || (index == length && !end) {
+		return 0, fmt.Errorf("index %d out of range, length %d: %w", index, length, ErrPathNotFound)
+	}
+	return index, nil
+}
@@ -0,0 +1,74 @@
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMapWrapper_GetPointer(t *testing.T) {
+	// the example document of RFC 6901
+	var data map[string]any
+	err := json.Unmarshal([]byte(`{
+		"foo": ["bar", "baz"],
+		"": 0,
+		"a/b": 1,
+		"c%d": 2,
+		"e^f": 3,
+		"g|h": 4,
+		"i\\j": 5,
+		"k\"l": 6,
+		" ": 7,
+		"m~n": 8
+	}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMapWrapper(data)
+
+	testCases := map[string]any{
+		"/foo":   []any{"bar", "baz"},
+		"/foo/0": "bar",
+		"/":      0.0,
+		"/a~1b":  1.0,
+		"/c%d":   2.0,
+		"/e^f":   3.0,
+		"/g|h":   4.0,
+		"/i\\j":  5.0,
+		"/k\"l":  6.0,
+		"/ ":     7.0,
+		"/m~0n":  8.0,
+	}
+	for ptr, expected := range testCases {
+		value, err := m.GetPointer(ptr)
+		if err != nil {
+			t.Errorf("%s: %v", ptr, err)
+		} else if !reflect.DeepEqual(value, expected) {
+			t.Errorf("%s: expected %v, got %v", ptr, expected, value)
+		}
+	}
+	if value, err := m.GetPointer(""); err != nil || !reflect.DeepEqual(value, data) {
+		t.Errorf("expected the whole document, got %v, %v", value, err)
+	}
+
+	for ptr, segment := range map[string]string{
+		"/foo/2":     "/foo/2",
+		"/foo/-":     "/foo/-",
+		"/missing/a": "/missing",
+	} {
+		var pathErr *PathError
+		if _, err = m.GetPointer(ptr); !errors.As(err, &pathErr) || pathErr.Segment != segment || !errors.Is(err, ErrPathNotFound) {
+			t.Errorf("%s: expected not found at %s, got %v", ptr, segment, err)
+		}
+	}
+	for _, ptr := range []string{"foo", "/foo/01", "/foo/+1", "/m~2n", "/a~", "/foo/0/x"} {
+		if _, err = m.GetPointer(ptr); err == nil || errors.Is(err, ErrPathNotFound) {
+			t.Errorf("%s: expected invalid pointer, got %v", ptr, err)
+		}
+	}
+
+	if ptr := formatPointer([]string{"a/b", "m~n", "0"}); ptr != "/a~1b/m~0n/0" {
+		t.Errorf("unexpected pointer %s", ptr)
+	}
+}